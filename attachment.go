@@ -0,0 +1,97 @@
+package ics
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrAttachmentTooLarge is returned by Attachment.Data when the attachment
+// was bigger than the maximum size allowed when the calendar was parsed.
+var ErrAttachmentTooLarge = errors.New("ics: attachment exceeds the maximum size")
+
+// Attachment is a document associated with an event, either referenced by
+// its URI or included inline in the calendar.
+type Attachment struct {
+	URI      string
+	FmtType  string
+	Filename string
+	// Size is the size of the document in bytes, or 0 if it is unknown. For
+	// inline attachments it is the size of the decoded data.
+	Size int
+
+	encoded  string
+	inline   bool
+	tooLarge bool
+}
+
+// Inline tells whether the data of the attachment is included in the
+// calendar instead of being referenced by URI.
+func (a Attachment) Inline() bool {
+	return a.inline
+}
+
+// Data decodes and returns the data of an inline attachment. Decoding is done
+// on every call, so callers should keep the result if they need it more than
+// once. It returns nil for attachments referenced by URI.
+func (a Attachment) Data() ([]byte, error) {
+	if !a.inline {
+		return nil, nil
+	}
+
+	if a.tooLarge {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	return decodeBase64(a.encoded)
+}
+
+// decodeBase64 decodes inline binary data, with or without padding.
+func decodeBase64(encoded string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return base64.RawStdEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	}
+
+	return data, nil
+}
+
+// decodedSize returns the size of the data encoded in base64.
+func decodedSize(encoded string) int {
+	return len(strings.TrimRight(encoded, "=")) * 3 / 4
+}
+
+// exceedsMaxSize tells whether size is bigger than maxSize, where 0 means
+// DefaultMaxAttachmentSize and a negative value means there is no limit.
+func exceedsMaxSize(size, maxSize int) bool {
+	if maxSize == 0 {
+		maxSize = DefaultMaxAttachmentSize
+	}
+	return maxSize >= 0 && size > maxSize
+}
+
+// parseAttachment parses an ATTACH property. Inline data bigger than maxSize
+// bytes is discarded, using the same limits as Options.MaxAttachmentSize.
+func parseAttachment(p property, maxSize int) Attachment {
+	a := Attachment{
+		FmtType:  p.param("FMTTYPE"),
+		Filename: firstNonEmpty(p.param("FILENAME"), p.param("X-FILENAME"), p.param("X-APPLE-FILENAME")),
+	}
+
+	if !strings.EqualFold(p.param("ENCODING"), "BASE64") && !strings.EqualFold(p.param("VALUE"), "BINARY") {
+		a.URI = strings.TrimSpace(p.Value)
+		a.Size, _ = strconv.Atoi(p.param("SIZE"))
+		return a
+	}
+
+	a.inline = true
+	a.Size = decodedSize(p.Value)
+	if exceedsMaxSize(a.Size, maxSize) {
+		a.tooLarge = true
+		return a
+	}
+
+	a.encoded = p.Value
+	return a
+}
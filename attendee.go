@@ -2,9 +2,62 @@ package ics
 
 // Attendee is a person either attending to the event or the organizer
 type Attendee struct {
-	Name   string
+	Name string
+	// Address is the calendar user address of the attendee, a URI such as
+	// "mailto:jane@example.com", "urn:uuid:...", "sip:..." or "tel:...".
+	Address string
+	// Email is taken from the EMAIL parameter or from a mailto: address, and
+	// is empty for other kinds of addresses.
 	Email  string
-	Status string
-	Role   string
-	Type   string
+	Status ParticipationStatus
+	Role   Role
+	Type   CalendarUserType
+	// RSVP tells whether the organizer expects a reply from the attendee.
+	RSVP bool
+	// DelegatedTo, DelegatedFrom and Member contain calendar user addresses,
+	// usually "mailto:" URIs.
+	DelegatedTo    []string
+	DelegatedFrom  []string
+	Member         []string
+	SentBy         string
+	Dir            string
+	Language       string
+	ScheduleStatus []string
 }
+
+// ParticipationStatus is the participation status of an attendee.
+type ParticipationStatus string
+
+// Participation statuses defined in RFC 5545.
+const (
+	StatusNeedsAction ParticipationStatus = "NEEDS-ACTION"
+	StatusAccepted    ParticipationStatus = "ACCEPTED"
+	StatusDeclined    ParticipationStatus = "DECLINED"
+	StatusTentative   ParticipationStatus = "TENTATIVE"
+	StatusDelegated   ParticipationStatus = "DELEGATED"
+	StatusCompleted   ParticipationStatus = "COMPLETED"
+	StatusInProcess   ParticipationStatus = "IN-PROCESS"
+)
+
+// Role is the participation role of an attendee.
+type Role string
+
+// Participation roles defined in RFC 5545.
+const (
+	RoleChair          Role = "CHAIR"
+	RoleRequired       Role = "REQ-PARTICIPANT"
+	RoleOptional       Role = "OPT-PARTICIPANT"
+	RoleNonParticipant Role = "NON-PARTICIPANT"
+)
+
+// CalendarUserType is the kind of calendar user an attendee is.
+type CalendarUserType string
+
+// Calendar user types defined in RFC 5545.
+const (
+	TypeIndividual CalendarUserType = "INDIVIDUAL"
+	TypeGroup      CalendarUserType = "GROUP"
+	TypeResource   CalendarUserType = "RESOURCE"
+	TypeRoom       CalendarUserType = "ROOM"
+	TypeUnknown    CalendarUserType = "UNKNOWN"
+)
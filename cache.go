@@ -0,0 +1,120 @@
+package ics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStore stores the cache entries of the calendars fetched by a Fetcher,
+// by URL. Implementations must be safe for concurrent use.
+type CacheStore interface {
+	// Get returns the entry stored for url, and false if there is none.
+	Get(url string) (CacheEntry, bool, error)
+	// Set stores the entry for url, replacing any previous one.
+	Set(url string, entry CacheEntry) error
+}
+
+// MemoryCache is a CacheStore that keeps the entries, including the content
+// of the calendars, in memory.
+type MemoryCache struct {
+	mut     sync.RWMutex
+	entries map[string]CacheEntry
+}
+
+// NewMemoryCache returns a new empty MemoryCache.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]CacheEntry)}
+}
+
+// Get implements the CacheStore interface.
+func (c *MemoryCache) Get(url string) (CacheEntry, bool, error) {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	entry, ok := c.entries[url]
+	return entry, ok, nil
+}
+
+// Set implements the CacheStore interface.
+func (c *MemoryCache) Set(url string, entry CacheEntry) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.entries[url] = entry
+	return nil
+}
+
+// DiskCache is a CacheStore that keeps each entry, including the content of
+// the calendar, in a JSON file inside a directory, so entries survive
+// restarts.
+type DiskCache struct {
+	dir string
+	mut sync.Mutex
+}
+
+// NewDiskCache returns a DiskCache storing its entries in dir, which is
+// created if it does not exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+// Get implements the CacheStore interface.
+func (c *DiskCache) Get(url string) (CacheEntry, bool, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	data, err := ioutil.ReadFile(c.path(url))
+	if os.IsNotExist(err) {
+		return CacheEntry{}, false, nil
+	} else if err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return CacheEntry{}, false, err
+	}
+
+	return entry, true, nil
+}
+
+// Set implements the CacheStore interface.
+func (c *DiskCache) Set(url string, entry CacheEntry) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial entries.
+	tmp, err := ioutil.TempFile(c.dir, ".entry")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), c.path(url))
+}
+
+func (c *DiskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
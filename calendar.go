@@ -1,17 +1,34 @@
 package ics
 
-import "time"
+import (
+	"net/url"
+	"sort"
+	"time"
+)
 
 // Calendar represents a single calendar with events
 type Calendar struct {
-	Name              string
-	Description       string
-	URL               string
-	Version           float64
-	Timezone          *time.Location
+	Name        string
+	Description string
+	URL         string
+	Version     float64
+	Timezone    *time.Location
+	ProdID      string
+	CalScale    string
+	Method      string
+	UID         string
+	Color       string
+	// RefreshInterval is how often the publisher suggests the calendar
+	// should be fetched again, from REFRESH-INTERVAL or X-PUBLISHED-TTL, or
+	// 0 if it does not say.
+	RefreshInterval   time.Duration
+	Source            *url.URL
+	Images            []Image
+	LastModified      time.Time
 	Events            []Event
 	TraceErrFunc      traceErrFunc
 	convertDatesToUTC bool
+	maxAttachmentSize int
 }
 
 // NewCalendar returns a new empty calendar instance
@@ -20,3 +37,17 @@ func NewCalendar() Calendar {
 		Events: []Event{},
 	}
 }
+
+// In returns a copy of the calendar with all its events, including the ones
+// generated by repetition rules, presented in loc. See Event.In for how whole
+// day events are handled.
+func (c Calendar) In(loc *time.Location) Calendar {
+	events := make([]Event, len(c.Events))
+	for i := range c.Events {
+		events[i] = *c.Events[i].In(loc)
+	}
+
+	c.Events = events
+	sort.Sort(byDate(c.Events))
+	return c
+}
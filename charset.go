@@ -0,0 +1,107 @@
+package ics
+
+import (
+	"mime"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// windows1252 maps the bytes 0x80 to 0x9F of Windows-1252 to their runes. The
+// rest of the bytes match ISO-8859-1. Undefined bytes map to the C1 control
+// with the same value, as browsers do.
+var windows1252 = [32]rune{
+	'€', '\u0081', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '\u008d', 'Ž', '\u008f',
+	'\u0090', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '\u009d', 'ž', 'Ÿ',
+}
+
+// iso885915 has the runes of ISO-8859-15 that differ from ISO-8859-1.
+var iso885915 = map[byte]rune{
+	0xa4: '€', 0xa6: 'Š', 0xa8: 'š', 0xb4: 'Ž', 0xb8: 'ž', 0xbc: 'Œ', 0xbd: 'œ', 0xbe: 'Ÿ',
+}
+
+// decodeText converts content to UTF-8. A byte order mark takes precedence
+// over the charset parameter of contentType, and content without either is
+// taken as UTF-8. Invalid sequences are replaced by U+FFFD.
+func decodeText(content []byte, contentType string) string {
+	switch {
+	case len(content) >= 3 && content[0] == 0xef && content[1] == 0xbb && content[2] == 0xbf:
+		return strings.ToValidUTF8(string(content[3:]), "�")
+	case len(content) >= 2 && content[0] == 0xff && content[1] == 0xfe:
+		return decodeUTF16(content[2:], false)
+	case len(content) >= 2 && content[0] == 0xfe && content[1] == 0xff:
+		return decodeUTF16(content[2:], true)
+	}
+
+	switch contentCharset(contentType) {
+	case "utf-16le":
+		return decodeUTF16(content, false)
+	case "utf-16", "utf-16be":
+		return decodeUTF16(content, true)
+	case "windows-1252", "cp1252", "x-cp1252":
+		return decodeSingleByte(content, func(b byte) rune {
+			if b >= 0x80 && b <= 0x9f {
+				return windows1252[b-0x80]
+			}
+			return rune(b)
+		})
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1":
+		return decodeSingleByte(content, func(b byte) rune { return rune(b) })
+	case "iso-8859-15", "iso8859-15", "iso_8859-15", "latin-9", "l9":
+		return decodeSingleByte(content, func(b byte) rune {
+			if r, ok := iso885915[b]; ok {
+				return r
+			}
+			return rune(b)
+		})
+	default:
+		return strings.ToValidUTF8(string(content), "�")
+	}
+}
+
+// contentCharset returns the lowercased charset parameter of a Content-Type
+// header, or an empty string if there is none.
+func contentCharset(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(strings.TrimSpace(params["charset"]))
+}
+
+func decodeSingleByte(content []byte, decode func(byte) rune) string {
+	var b strings.Builder
+	b.Grow(len(content))
+	for _, c := range content {
+		if c < utf8.RuneSelf {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteRune(decode(c))
+	}
+	return b.String()
+}
+
+// decodeUTF16 decodes UTF-16 content. A trailing odd byte is replaced by
+// U+FFFD, as are unpaired surrogates.
+func decodeUTF16(content []byte, bigEndian bool) string {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(content[2*i])<<8 | uint16(content[2*i+1])
+		} else {
+			units[i] = uint16(content[2*i+1])<<8 | uint16(content[2*i])
+		}
+	}
+
+	text := string(utf16.Decode(units))
+	if len(content)%2 != 0 {
+		text += "�"
+	}
+	return text
+}
@@ -0,0 +1,145 @@
+package ics
+
+import (
+	"reflect"
+	"time"
+)
+
+// Changes are the differences between two versions of a calendar, as
+// returned by Compare.
+type Changes struct {
+	Added    []Event
+	Removed  []Event
+	Modified []EventChange
+}
+
+// Empty tells whether no event was added, removed or modified.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// EventChange is an event present in both versions of a calendar with
+// different values.
+type EventChange struct {
+	Old Event
+	New Event
+	// Fields are the names of the Event fields that changed, in the order
+	// they are declared.
+	Fields []string
+	// Outdated is true if the new version of the event has a lower
+	// SEQUENCE, or the same one and an older LAST-MODIFIED, than the old
+	// version, so it is probably a stale copy that should be ignored.
+	Outdated bool
+}
+
+// Compare returns the events added, removed and modified in the updated
+// version of a calendar. Events are matched by UID and RECURRENCE-ID.
+// Expanded occurrences of repeating events, and other events sharing the same
+// UID without a RECURRENCE-ID, are told apart by their start. DTSTAMP is not
+// compared, since many publishers set it to the time the calendar is
+// exported.
+func Compare(old, updated Calendar) Changes {
+	var changes Changes
+
+	oldEvents := make(map[string]Event)
+	for _, key := range eventKeys(old.Events) {
+		oldEvents[key.key] = old.Events[key.index]
+	}
+
+	matched := make(map[string]bool)
+	for _, key := range eventKeys(updated.Events) {
+		e := updated.Events[key.index]
+		o, ok := oldEvents[key.key]
+		if !ok {
+			changes.Added = append(changes.Added, e)
+			continue
+		}
+
+		matched[key.key] = true
+		if fields := changedFields(o, e); len(fields) > 0 {
+			changes.Modified = append(changes.Modified, EventChange{
+				Old:      o,
+				New:      e,
+				Fields:   fields,
+				Outdated: e.Sequence < o.Sequence || (e.Sequence == o.Sequence && e.Modified.Before(o.Modified)),
+			})
+		}
+	}
+
+	for _, key := range eventKeys(old.Events) {
+		if !matched[key.key] {
+			changes.Removed = append(changes.Removed, old.Events[key.index])
+		}
+	}
+
+	return changes
+}
+
+type eventKey struct {
+	key   string
+	index int
+}
+
+// eventKeys returns the keys identifying events, in the same order.
+func eventKeys(events []Event) []eventKey {
+	instances := make(map[string]int)
+	for _, e := range events {
+		if e.RecurrenceID.IsZero() {
+			instances[e.ID]++
+		}
+	}
+
+	keys := make([]eventKey, len(events))
+	for i, e := range events {
+		key := e.ID
+		switch {
+		case !e.RecurrenceID.IsZero():
+			key += "\x00" + e.RecurrenceID.UTC().Format(icsFormat)
+		case instances[e.ID] > 1:
+			key += "\x00" + e.Start.UTC().Format(icsFormat)
+		}
+		keys[i] = eventKey{key: key, index: i}
+	}
+
+	return keys
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// changedFields returns the names of the fields that differ between two
+// versions of an event, ignoring DTStamp. Times are equal if they are the
+// same instant, whatever their location.
+func changedFields(old, updated Event) []string {
+	var fields []string
+	v1, v2 := reflect.ValueOf(old), reflect.ValueOf(updated)
+	for i := 0; i < v1.NumField(); i++ {
+		field := v1.Type().Field(i)
+		if field.Name == "DTStamp" {
+			continue
+		}
+
+		if !equalValues(v1.Field(i), v2.Field(i)) {
+			fields = append(fields, field.Name)
+		}
+	}
+	return fields
+}
+
+func equalValues(v1, v2 reflect.Value) bool {
+	switch {
+	case v1.Type() == timeType:
+		return v1.Interface().(time.Time).Equal(v2.Interface().(time.Time))
+	case v1.Kind() == reflect.Slice && v1.Type().Elem() == timeType:
+		if v1.Len() != v2.Len() {
+			return false
+		}
+		for i := 0; i < v1.Len(); i++ {
+			if !equalValues(v1.Index(i), v2.Index(i)) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(v1.Interface(), v2.Interface())
+	}
+}
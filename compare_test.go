@@ -0,0 +1,181 @@
+package ics
+
+import (
+	"reflect"
+	"testing"
+)
+
+var testCompareOld = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:same@example.com
+DTSTAMP:20240101T000000Z
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+SUMMARY:Standup
+END:VEVENT
+BEGIN:VEVENT
+UID:moved@example.com
+SEQUENCE:1
+DTSTART:20240111T100000Z
+DTEND:20240111T110000Z
+SUMMARY:Review
+LOCATION:Room 1
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled@example.com
+DTSTART:20240112T100000Z
+DTEND:20240112T110000Z
+SUMMARY:Lunch
+END:VEVENT
+BEGIN:VEVENT
+UID:stale@example.com
+SEQUENCE:3
+DTSTART:20240113T100000Z
+DTEND:20240113T110000Z
+SUMMARY:Planning
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@example.com
+RECURRENCE-ID:20240115T100000Z
+DTSTART:20240115T100000Z
+DTEND:20240115T110000Z
+SUMMARY:Weekly
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@example.com
+RECURRENCE-ID:20240122T100000Z
+DTSTART:20240122T100000Z
+DTEND:20240122T110000Z
+SUMMARY:Weekly
+END:VEVENT
+END:VCALENDAR
+`
+
+var testCompareNew = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:same@example.com
+DTSTAMP:20240105T000000Z
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+SUMMARY:Standup
+END:VEVENT
+BEGIN:VEVENT
+UID:moved@example.com
+SEQUENCE:2
+DTSTART:20240111T120000Z
+DTEND:20240111T130000Z
+SUMMARY:Review
+LOCATION:Room 2
+END:VEVENT
+BEGIN:VEVENT
+UID:stale@example.com
+SEQUENCE:2
+DTSTART:20240113T100000Z
+DTEND:20240113T110000Z
+SUMMARY:Old planning
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@example.com
+RECURRENCE-ID:20240115T100000Z
+DTSTART:20240115T100000Z
+DTEND:20240115T110000Z
+SUMMARY:Weekly
+END:VEVENT
+BEGIN:VEVENT
+UID:weekly@example.com
+RECURRENCE-ID:20240122T100000Z
+DTSTART:20240123T100000Z
+DTEND:20240123T110000Z
+SUMMARY:Weekly
+END:VEVENT
+BEGIN:VEVENT
+UID:new@example.com
+DTSTART:20240116T100000Z
+DTEND:20240116T110000Z
+SUMMARY:Offsite
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestCompare(t *testing.T) {
+	old, err := ParseICalContent(testCompareOld, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := ParseICalContent(testCompareNew, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changes := Compare(old, old); !changes.Empty() {
+		t.Errorf("expected no changes comparing a calendar with itself, got %+v", changes)
+	}
+
+	changes := Compare(old, updated)
+	if len(changes.Added) != 1 || changes.Added[0].ID != "new@example.com" {
+		t.Errorf("unexpected added events %+v", changes.Added)
+	}
+
+	if len(changes.Removed) != 1 || changes.Removed[0].ID != "cancelled@example.com" {
+		t.Errorf("unexpected removed events %+v", changes.Removed)
+	}
+
+	modified := make(map[string]EventChange)
+	for _, c := range changes.Modified {
+		modified[c.New.ID] = c
+	}
+
+	if len(modified) != 3 {
+		t.Fatalf("expected 3 modified events, got %+v", changes.Modified)
+	}
+
+	if c := modified["moved@example.com"]; c.Outdated || !reflect.DeepEqual(c.Fields, []string{"Start", "End", "Location", "Sequence", "Texts"}) {
+		t.Errorf("unexpected change %v outdated %v", c.Fields, c.Outdated)
+	}
+
+	if c := modified["stale@example.com"]; !c.Outdated || !reflect.DeepEqual(c.Fields, []string{"Summary", "Sequence", "Texts"}) {
+		t.Errorf("expected stale change to be outdated, got %v outdated %v", c.Fields, c.Outdated)
+	}
+
+	if c := modified["weekly@example.com"]; !c.Old.RecurrenceID.Equal(c.New.RecurrenceID) || !reflect.DeepEqual(c.Fields, []string{"Start", "End"}) {
+		t.Errorf("expected occurrence to be matched by recurrence id, got %v", c.Fields)
+	}
+}
+
+func recurringCalendar(dtstart, dtend string) string {
+	return "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:series@example.com\nSEQUENCE:4\n" +
+		"DTSTART:" + dtstart + "\nDTEND:" + dtend + "\nRRULE:FREQ=WEEKLY;COUNT=4\nSUMMARY:Series\n" +
+		"END:VEVENT\nEND:VCALENDAR\n"
+}
+
+func TestCompareRecurring(t *testing.T) {
+	parse := func(dtstart, dtend string, maxRepeats int) Calendar {
+		cal, err := ParseICalContent(recurringCalendar(dtstart, dtend), "", maxRepeats, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cal
+	}
+
+	changes := Compare(parse("20240610T100000Z", "20240610T110000Z", 0), parse("20240610T120000Z", "20240610T130000Z", 0))
+	if len(changes.Added) != 0 || len(changes.Removed) != 0 || len(changes.Modified) != 1 ||
+		!reflect.DeepEqual(changes.Modified[0].Fields, []string{"Start", "End"}) {
+		t.Errorf("expected rescheduled series to be modified, got %+v", changes)
+	}
+
+	old, updated := parse("20240610T100000Z", "20240610T110000Z", 10), parse("20240617T100000Z", "20240617T110000Z", 10)
+	for _, e := range updated.Events {
+		if e.Sequence != 4 {
+			t.Errorf("expected occurrence at %s to keep the sequence of the series, got %d", e.Start, e.Sequence)
+		}
+	}
+
+	changes = Compare(old, updated)
+	if len(changes.Modified) != 0 || len(changes.Removed) != 1 || len(changes.Added) != 1 ||
+		!changes.Removed[0].Start.Equal(old.Events[0].Start) || !changes.Added[0].Start.Equal(updated.Events[3].Start) {
+		t.Errorf("expected only the first occurrence removed and a new last one, got %+v", changes)
+	}
+}
@@ -0,0 +1,64 @@
+package ics
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrInvalidCompression is returned when a compressed calendar cannot be
+// decompressed.
+var ErrInvalidCompression = errors.New("ics: invalid compressed calendar")
+
+// decompress returns content decompressed if it starts with the gzip magic
+// bytes, or if encoding, the value of a Content-Encoding header, is deflate.
+// Other content is returned as is. Decompression fails with ErrBodyTooLarge
+// as soon as the result is bigger than maxSize bytes, unless maxSize is
+// negative.
+func decompress(content []byte, encoding string, maxSize int64) ([]byte, error) {
+	var (
+		r   io.Reader
+		err error
+	)
+
+	switch {
+	case len(content) >= 2 && content[0] == 0x1f && content[1] == 0x8b:
+		r, err = gzip.NewReader(bytes.NewReader(content))
+	case strings.EqualFold(strings.TrimSpace(encoding), "deflate"):
+		// Some servers send raw deflate data instead of the zlib format
+		// required by HTTP.
+		r, err = zlib.NewReader(bytes.NewReader(content))
+		if err != nil {
+			r, err = flate.NewReader(bytes.NewReader(content)), nil
+		}
+	default:
+		return content, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCompression, err)
+	}
+
+	if maxSize >= 0 {
+		r = io.LimitReader(r, maxSize+1)
+	}
+
+	// The errors are not wrapped, since errors such as io.ErrUnexpectedEOF
+	// would make them look like network errors.
+	decompressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCompression, err)
+	}
+
+	if maxSize >= 0 && int64(len(decompressed)) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	return decompressed, nil
+}
@@ -0,0 +1,178 @@
+package ics
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Conference is a way to join an event remotely, such as a video call link
+// or a dial-in number, as defined by the CONFERENCE property of RFC 7986.
+type Conference struct {
+	URI string
+	// Features lists what the conference supports: AUDIO, CHAT, FEED,
+	// MODERATOR, PHONE, SCREEN or VIDEO.
+	Features []string
+	Label    string
+	Language string
+}
+
+func parseConference(p property) Conference {
+	return Conference{
+		URI:      p.Value,
+		Features: p.Params["FEATURE"],
+		Label:    p.param("LABEL"),
+		Language: p.param("LANGUAGE"),
+	}
+}
+
+// Conference providers recognized by Event.ConferenceInfo.
+const (
+	ProviderGoogleMeet = "Google Meet"
+	ProviderTeams      = "Microsoft Teams"
+	ProviderZoom       = "Zoom"
+	ProviderWebex      = "Webex"
+)
+
+// ConferenceInfo describes how to join an event remotely.
+type ConferenceInfo struct {
+	// Provider is one of the Provider constants, or empty if the join URL
+	// belongs to an unknown service.
+	Provider  string
+	JoinURL   string
+	DialIns   []string
+	MeetingID string
+}
+
+var (
+	conferenceURLRegex       = regexp.MustCompile(`https://[^\s"'<>\\]+`)
+	conferencePhoneRegex     = regexp.MustCompile(`\+\d[\d \-().]{6,}\d`)
+	conferenceMeetingIDRegex = regexp.MustCompile(`(?i)(?:meeting id|conference id|pin)\s*[:#]?\s*(\d[\d ]{4,}\d)`)
+	zoomMeetingIDRegex       = regexp.MustCompile(`/(?:j|w|s)/(\d+)`)
+	googleMeetingIDRegex     = regexp.MustCompile(`^/([a-z]{3}-[a-z]{4}-[a-z]{3})`)
+)
+
+// ConferenceInfo looks for the details needed to join the event remotely.
+// Sources are inspected in order: the CONFERENCE properties, the
+// X-GOOGLE-CONFERENCE and X-MICROSOFT-SKYPETEAMSMEETINGURL properties, and
+// finally links to known providers in the location and the description.
+// It returns false if no join URL or dial-in number is found.
+func (e *Event) ConferenceInfo() (ConferenceInfo, bool) {
+	var info ConferenceInfo
+	description := unescapeText(e.Description)
+
+	for _, c := range e.Conferences {
+		if strings.HasPrefix(strings.ToLower(c.URI), "tel:") {
+			info.DialIns = appendPhone(info.DialIns, c.URI[len("tel:"):])
+		} else if info.JoinURL == "" && isWebURL(c.URI) {
+			info.JoinURL = c.URI
+		}
+	}
+
+	if info.JoinURL == "" {
+		for _, name := range []string{"X-GOOGLE-CONFERENCE", "X-MICROSOFT-SKYPETEAMSMEETINGURL"} {
+			if values := e.XProperties[name]; len(values) > 0 && isWebURL(values[0]) {
+				info.JoinURL = values[0]
+				break
+			}
+		}
+	}
+
+	if info.JoinURL == "" {
+		for _, text := range []string{unescapeText(e.Location), description} {
+			for _, u := range conferenceURLRegex.FindAllString(text, -1) {
+				if conferenceProvider(u) != "" {
+					info.JoinURL = u
+					break
+				}
+			}
+
+			if info.JoinURL != "" {
+				break
+			}
+		}
+	}
+
+	info.Provider = conferenceProvider(info.JoinURL)
+	for _, phone := range conferencePhoneRegex.FindAllString(description, -1) {
+		info.DialIns = appendPhone(info.DialIns, phone)
+	}
+
+	info.MeetingID = conferenceMeetingID(info, description)
+	return info, info.JoinURL != "" || len(info.DialIns) > 0
+}
+
+func isWebURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
+
+func conferenceProvider(joinURL string) string {
+	u, err := url.Parse(joinURL)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	switch {
+	case host == "meet.google.com":
+		return ProviderGoogleMeet
+	case host == "teams.microsoft.com" || host == "teams.live.com":
+		return ProviderTeams
+	case host == "zoom.us" || strings.HasSuffix(host, ".zoom.us") || host == "zoomgov.com" || strings.HasSuffix(host, ".zoomgov.com"):
+		return ProviderZoom
+	case host == "webex.com" || strings.HasSuffix(host, ".webex.com"):
+		return ProviderWebex
+	}
+
+	return ""
+}
+
+func conferenceMeetingID(info ConferenceInfo, description string) string {
+	if u, err := url.Parse(info.JoinURL); err == nil {
+		switch info.Provider {
+		case ProviderGoogleMeet:
+			if m := googleMeetingIDRegex.FindStringSubmatch(u.Path); m != nil {
+				return m[1]
+			}
+		case ProviderZoom:
+			if m := zoomMeetingIDRegex.FindStringSubmatch(u.Path); m != nil {
+				return m[1]
+			}
+		}
+	}
+
+	if m := conferenceMeetingIDRegex.FindStringSubmatch(description); m != nil {
+		return strings.Replace(m[1], " ", "", -1)
+	}
+
+	return ""
+}
+
+// appendPhone adds phone to phones normalized to its digits and a leading
+// plus sign, dropping the access codes that follow commas or semicolons.
+func appendPhone(phones []string, phone string) []string {
+	if i := strings.IndexAny(phone, ",;"); i >= 0 {
+		phone = phone[:i]
+	}
+
+	var b strings.Builder
+	for i, r := range phone {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+
+	normalized := b.String()
+	if len(strings.TrimPrefix(normalized, "+")) < 7 {
+		return phones
+	}
+
+	for _, p := range phones {
+		if p == normalized {
+			return phones
+		}
+	}
+
+	return append(phones, normalized)
+}
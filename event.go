@@ -1,7 +1,9 @@
 package ics
 
 import (
+	"net/url"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -25,6 +27,43 @@ type Event struct {
 	Organizer     Attendee
 	WholeDayEvent bool
 	ExDates       []time.Time
+	Conferences   []Conference
+	Color         string
+	Images        []Image
+	Categories    []string
+	Resources     []string
+	// Priority goes from 1, the highest, to 9, the lowest. 0 means the
+	// priority is undefined.
+	Priority     int
+	Transparency string
+	URL          *url.URL
+	Geo          *Geo
+	Comments     []string
+	Contacts     []string
+	RelatedTo    []string
+	DTStamp      time.Time
+	Attachments  []Attachment
+	// HTMLDescription is the description as HTML, as found in the
+	// X-ALT-DESC property written by Outlook.
+	HTMLDescription string
+	// DescriptionAltRep is the URI of an alternate representation of the
+	// description, given by the ALTREP parameter.
+	DescriptionAltRep string
+	// Texts holds the values of the text properties of the event, such as
+	// SUMMARY or DESCRIPTION, by property name along with their language.
+	Texts map[string][]LocalizedText
+	// Places holds the structured locations of the event, which complement
+	// the free form Location.
+	Places []Place
+	// XProperties holds the values of the non-standard properties of the
+	// event, such as X-GOOGLE-CONFERENCE, by property name.
+	XProperties map[string][]string
+}
+
+// Geo is a position on Earth, in degrees.
+type Geo struct {
+	Latitude  float64
+	Longitude float64
 }
 
 type byDate []Event
@@ -54,6 +93,43 @@ func (e *Event) Clone() *Event {
 	return &newEvent
 }
 
+// In returns a copy of the event with its dates presented in loc. Whole day
+// events keep their calendar date and become midnight to midnight in loc, so
+// a day-long event never spills into the previous or next day.
+func (e *Event) In(loc *time.Location) *Event {
+	newEvent := e.Clone()
+	newEvent.Start = inLocation(e.Start, loc, e.WholeDayEvent)
+	newEvent.End = inLocation(e.End, loc, e.WholeDayEvent)
+	if !e.RecurrenceID.IsZero() {
+		newEvent.RecurrenceID = inLocation(e.RecurrenceID, loc, e.WholeDayEvent)
+	}
+
+	if e.ExDates != nil {
+		newEvent.ExDates = make([]time.Time, len(e.ExDates))
+		for i, d := range e.ExDates {
+			newEvent.ExDates[i] = inLocation(d, loc, e.WholeDayEvent)
+		}
+	}
+
+	return newEvent
+}
+
+func inLocation(t time.Time, loc *time.Location, wholeDay bool) time.Time {
+	if wholeDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	return t.In(loc)
+}
+
+// PlainDescription returns the description as plain text. If the event has
+// only an HTML description, its text is extracted from the markup.
+func (e *Event) PlainDescription() string {
+	if desc := unescapeText(e.Description); strings.TrimSpace(desc) != "" {
+		return desc
+	}
+	return htmlToText(e.HTMLDescription)
+}
+
 func (e *Event) Equals(e2 *Event) bool {
 	return e.Start.Equal(e2.Start) && e.End.Equal(e2.End) && e.Summary == e2.Summary
 }
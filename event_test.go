@@ -63,3 +63,130 @@ func d(t string) time.Time {
 	tm, _ := time.Parse(icsFormat, t)
 	return tm
 }
+
+func TestCalendarIn(t *testing.T) {
+	content := "BEGIN:VCALENDAR\nVERSION:2.0\n" +
+		"BEGIN:VEVENT\n" +
+		"DTSTART;TZID=America/New_York:20240610T190000\n" +
+		"DTEND;TZID=America/New_York:20240610T200000\n" +
+		"RRULE:FREQ=DAILY;COUNT=2\n" +
+		"UID:timed@example.com\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VEVENT\n" +
+		"DTSTART;VALUE=DATE:20240610\n" +
+		"DTEND;VALUE=DATE:20240611\n" +
+		"UID:whole@example.com\n" +
+		"END:VEVENT\n" +
+		"BEGIN:VEVENT\n" +
+		"DTSTART;VALUE=DATE:20240613\n" +
+		"UID:noend@example.com\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+
+	calendar, err := ParseICalContent(content, "", 10, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.FailNow()
+	}
+
+	result := calendar.In(tokyo)
+	if len(result.Events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(result.Events))
+	}
+
+	expected := []struct {
+		wholeDay bool
+		start    time.Time
+		end      time.Time
+	}{
+		{true, time.Date(2024, time.June, 10, 0, 0, 0, 0, tokyo), time.Date(2024, time.June, 11, 0, 0, 0, 0, tokyo)},
+		{false, time.Date(2024, time.June, 11, 8, 0, 0, 0, tokyo), time.Date(2024, time.June, 11, 9, 0, 0, 0, tokyo)},
+		{false, time.Date(2024, time.June, 12, 8, 0, 0, 0, tokyo), time.Date(2024, time.June, 12, 9, 0, 0, 0, tokyo)},
+		{true, time.Date(2024, time.June, 13, 0, 0, 0, 0, tokyo), time.Date(2024, time.June, 14, 0, 0, 0, 0, tokyo)},
+	}
+
+	for i, e := range result.Events {
+		if e.WholeDayEvent != expected[i].wholeDay {
+			t.Errorf("expected event %d whole day to be %v", i, expected[i].wholeDay)
+		}
+
+		if !e.Start.Equal(expected[i].start) || e.Start.Location() != tokyo {
+			t.Errorf("expected event %d to start at %s, got %s", i, expected[i].start, e.Start)
+		}
+
+		if !e.End.Equal(expected[i].end) || e.End.Location() != tokyo {
+			t.Errorf("expected event %d to end at %s, got %s", i, expected[i].end, e.End)
+		}
+	}
+
+	if calendar.Events[0].Start.Location() == tokyo {
+		t.Errorf("expected original calendar to be left untouched")
+	}
+}
+
+var testMultilingualEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:multilingual@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+SUMMARY:Annual meeting
+SUMMARY;LANGUAGE=de:Jahresversammlung
+SUMMARY;LANGUAGE=fr-CA:Assemblée annuelle
+SUMMARY;LANGUAGE=es-ES:Reunión anual
+DESCRIPTION;LANGUAGE=en:Main hall
+DESCRIPTION;LANGUAGE=de-AT:Großer Saal
+LOCATION;LANGUAGE=de-CH:Zürich
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestEventLocalized(t *testing.T) {
+	calendar, err := ParseICalContent(testMultilingualEvent, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := calendar.Events[0]
+	if len(event.Texts["SUMMARY"]) != 4 || event.Texts["SUMMARY"][2].Language != "fr-CA" {
+		t.Errorf("unexpected summaries %+v", event.Texts["SUMMARY"])
+	}
+
+	cases := []struct {
+		lang        string
+		summary     string
+		description string
+	}{
+		{"de", "Jahresversammlung", "Großer Saal"},
+		{"de-CH", "Jahresversammlung", "Großer Saal"},
+		{"fr", "Assemblée annuelle", "Main hall"},
+		{"fr_CA", "Assemblée annuelle", "Main hall"},
+		{"ES-es", "Reunión anual", "Main hall"},
+		{"en-GB", "Annual meeting", "Main hall"},
+		{"ja", "Annual meeting", "Main hall"},
+		{"", "Annual meeting", "Main hall"},
+	}
+
+	for _, c := range cases {
+		localized := event.Localized(c.lang)
+		if localized.Summary != c.summary {
+			t.Errorf("%q: expected summary %q, got %q", c.lang, c.summary, localized.Summary)
+		}
+
+		if localized.Description != c.description {
+			t.Errorf("%q: expected description %q, got %q", c.lang, c.description, localized.Description)
+		}
+
+		if localized.Location != "Zürich" {
+			t.Errorf("%q: expected location %q, got %q", c.lang, "Zürich", localized.Location)
+		}
+	}
+
+	if event.Summary != "Annual meeting" {
+		t.Errorf("expected original event to be left untouched, got summary %q", event.Summary)
+	}
+}
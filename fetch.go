@@ -0,0 +1,360 @@
+package ics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	neturl "net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// CacheEntry holds the validators sent by a server along with a calendar,
+// used to ask the server for the calendar only if it has changed, and the
+// content of the calendar, so it can be recovered when it did not change.
+type CacheEntry struct {
+	ETag         string
+	LastModified string
+	Content      string
+}
+
+var (
+	// ErrNotICalendar is returned when a downloaded document is not an
+	// iCalendar object, such as the HTML of an error or a login page.
+	ErrNotICalendar = errors.New("ics: content is not an iCalendar object")
+	// ErrBodyTooLarge is returned when a downloaded calendar is bigger than
+	// the maximum size allowed by Options.MaxBodySize.
+	ErrBodyTooLarge = errors.New("ics: calendar exceeds the maximum size")
+)
+
+// HTTPError is returned when the server of a remote calendar replies with a
+// status other than 2xx.
+type HTTPError struct {
+	StatusCode int
+	Status     string
+	// URL is the URL of the calendar, without credentials.
+	URL string
+	// RetryAfter is the delay asked by the server with a Retry-After
+	// header, or 0 if there was none.
+	RetryAfter time.Duration
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("ics: unexpected status %q fetching %s", e.Status, e.URL)
+}
+
+// resolveCalendarURL tells where to get the calendar at rawurl from. Remote
+// calendars are returned as the http or https URL to download them from,
+// mapping the webcal and webcals schemes used by subscription links to them.
+// Local calendars, given as a path or as a file URL, are returned as a path.
+func resolveCalendarURL(rawurl string) (remote, path string, err error) {
+	m := urlSchemeRegex.FindStringSubmatch(rawurl)
+	// A single letter is the drive of a Windows path, not a scheme.
+	if m == nil || len(m[1]) == 1 {
+		return "", rawurl, nil
+	}
+
+	scheme := strings.ToLower(m[1])
+	rest := rawurl[len(m[0]):]
+	switch scheme {
+	case "http", "https":
+		return scheme + ":" + rest, "", nil
+	case "webcal":
+		return "http:" + rest, "", nil
+	case "webcals":
+		return "https:" + rest, "", nil
+	case "file":
+		u, err := neturl.Parse(rawurl)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid file URL %s", redactURL(rawurl))
+		}
+
+		if u.Host != "" && u.Host != "localhost" {
+			return "", "", fmt.Errorf("file URL %s is not on the local host", redactURL(rawurl))
+		}
+
+		if u.Path == "" {
+			return "", u.Opaque, nil
+		}
+
+		return "", filepath.FromSlash(u.Path), nil
+	default:
+		return "", "", fmt.Errorf("unsupported URL scheme %q in %s", m[1], redactURL(rawurl))
+	}
+}
+
+// redactURL removes the credentials from rawurl so it can be shown in
+// calendars and errors.
+func redactURL(rawurl string) string {
+	u, err := neturl.Parse(rawurl)
+	if err != nil {
+		return userinfoRegex.ReplaceAllString(rawurl, "$1")
+	}
+
+	if u.User == nil {
+		return rawurl
+	}
+
+	u.User = nil
+	return u.String()
+}
+
+// newRequest returns a GET request for url. Credentials in the URL are moved
+// to a basic authorization header, then opts can set their own headers.
+func newRequest(ctx context.Context, url string, opts Options) (*http.Request, error) {
+	u, err := neturl.Parse(url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %s", redactURL(url))
+	}
+
+	user := u.User
+	u.User = nil
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if user != nil {
+		password, _ := user.Password()
+		req.SetBasicAuth(user.Username(), password)
+	}
+
+	// Headers from opts replace the ones set above, such as the
+	// authorization from the URL credentials.
+	for name, values := range opts.Header {
+		req.Header.Del(name)
+		for _, v := range values {
+			req.Header.Add(name, v)
+		}
+	}
+
+	if opts.PrepareRequest != nil {
+		if err := opts.PrepareRequest(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req.WithContext(ctx), nil
+}
+
+// download gets the calendar at url, retrying as configured by opts.Retry.
+// If the entry has validators they are sent as conditional headers and
+// notModified is true if the server replies that the calendar did not
+// change, in which case content is empty.
+func download(ctx context.Context, url string, opts Options, entry CacheEntry) (content string, newEntry CacheEntry, notModified bool, err error) {
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest(ctx, url, opts)
+		if err != nil {
+			return "", CacheEntry{}, false, err
+		}
+
+		content, newEntry, notModified, err = downloadOnce(req, opts, entry)
+		if err == nil {
+			return content, newEntry, notModified, nil
+		}
+
+		if attempt >= opts.Retry.MaxAttempts || !retryable(ctx, req, err) {
+			return "", CacheEntry{}, false, attemptsError(attempt, err)
+		}
+
+		delay, ok := opts.Retry.delay(attempt, err)
+		if !ok {
+			return "", CacheEntry{}, false, attemptsError(attempt, err)
+		}
+
+		if err := opts.Retry.sleep(ctx, delay); err != nil {
+			return "", CacheEntry{}, false, &RetryError{Attempts: attempt, Err: err}
+		}
+	}
+}
+
+// downloadOnce sends req, which is conditional if the entry has validators.
+func downloadOnce(req *http.Request, opts Options, entry CacheEntry) (content string, newEntry CacheEntry, notModified bool, err error) {
+
+	if entry.ETag != "" {
+		req.Header.Set("If-None-Match", entry.ETag)
+	}
+
+	if entry.LastModified != "" {
+		req.Header.Set("If-Modified-Since", entry.LastModified)
+	}
+
+	response, err := opts.client().Do(req)
+	if err != nil {
+		return "", CacheEntry{}, false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotModified && (entry.ETag != "" || entry.LastModified != "") {
+		return "", entry, true, nil
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return "", CacheEntry{}, false, &HTTPError{
+			StatusCode: response.StatusCode,
+			Status:     response.Status,
+			URL:        req.URL.String(),
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), opts.Retry.now()),
+		}
+	}
+
+	contents, err := readBody(response, opts.maxBodySize())
+	if err == nil {
+		contents, err = decompress(contents, response.Header.Get("Content-Encoding"), opts.maxBodySize())
+	}
+	if err != nil {
+		return "", CacheEntry{}, false, fmt.Errorf("ics: reading %s: %w", req.URL, err)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	content = decodeText(contents, contentType)
+	if !looksLikeICalendar(content) {
+		return "", CacheEntry{}, false, fmt.Errorf("ics: %s with content type %q: %w", req.URL, contentType, ErrNotICalendar)
+	}
+
+	newEntry = CacheEntry{
+		ETag:         response.Header.Get("ETag"),
+		LastModified: response.Header.Get("Last-Modified"),
+	}
+
+	return content, newEntry, false, nil
+}
+
+// readBody reads the body of response, failing with ErrBodyTooLarge as soon
+// as it is known to be bigger than maxSize bytes. There is no limit if
+// maxSize is negative.
+func readBody(response *http.Response, maxSize int64) ([]byte, error) {
+	if maxSize < 0 {
+		return ioutil.ReadAll(response.Body)
+	}
+
+	if response.ContentLength > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(response.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(contents)) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+
+	return contents, nil
+}
+
+// looksLikeICalendar tells whether content starts like an iCalendar object,
+// ignoring leading blank space.
+func looksLikeICalendar(content string) bool {
+	content = strings.TrimLeft(content, " \t\r\n")
+	prefix := "BEGIN:VCALENDAR"
+	return len(content) >= len(prefix) && strings.EqualFold(content[:len(prefix)], prefix)
+}
+
+// FetchResult is the result of fetching a calendar with a Fetcher.
+type FetchResult struct {
+	// Calendar is the parsed calendar. It is empty if NotModified is true.
+	Calendar Calendar
+	// NotModified is true if the server replied that the calendar did not
+	// change since it was last fetched.
+	NotModified bool
+}
+
+// Fetcher fetches calendars remembering the ETag and Last-Modified headers
+// sent by the servers, so calendars that did not change are neither
+// downloaded nor parsed again.
+type Fetcher struct {
+	Options Options
+	// Cache stores the validators of the fetched calendars. Requests are
+	// not conditional if it is nil.
+	Cache CacheStore
+}
+
+// NewFetcher returns a fetcher using the given options and cache.
+func NewFetcher(opts Options, cache CacheStore) *Fetcher {
+	return &Fetcher{Options: opts, Cache: cache}
+}
+
+// Fetch gets and parses the calendar at url, which can also be a local path.
+// When the server replies that the remote calendar did not change since the
+// last fetch the result has NotModified set, and the caller should keep
+// using the calendar it got before. Callers that don't have it, such as after
+// a restart with a DiskCache, can get it from Cached.
+func (f *Fetcher) Fetch(ctx context.Context, url string) (FetchResult, error) {
+	return f.fetch(ctx, url, true)
+}
+
+// Cached returns the calendar at url as it was last fetched, and false if
+// the cache has no calendar for url.
+func (f *Fetcher) Cached(url string) (Calendar, bool, error) {
+	remote, _, err := resolveCalendarURL(url)
+	if err != nil || remote == "" || f.Cache == nil {
+		return Calendar{}, false, err
+	}
+
+	entry, ok, err := f.Cache.Get(remote)
+	if err != nil || !ok || entry.Content == "" {
+		return Calendar{}, false, err
+	}
+
+	cal, err := ParseICalContentWithOptions(entry.Content, url, f.Options)
+	if err != nil {
+		return Calendar{}, false, err
+	}
+
+	return cal, true, nil
+}
+
+// fetch is like Fetch, but the request is only conditional if conditional
+// is true and the cache has the calendar to fall back to.
+func (f *Fetcher) fetch(ctx context.Context, url string, conditional bool) (FetchResult, error) {
+	remote, _, err := resolveCalendarURL(url)
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	if remote == "" {
+		cal, err := ParseCalendarContext(ctx, url, f.Options)
+		return FetchResult{Calendar: cal}, err
+	}
+
+	var entry CacheEntry
+	if f.Cache != nil && conditional {
+		cached, ok, err := f.Cache.Get(remote)
+		if err != nil {
+			return FetchResult{}, err
+		}
+
+		if ok && cached.Content != "" {
+			entry = cached
+		}
+	}
+
+	content, entry, notModified, err := download(ctx, remote, f.Options, entry)
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	if notModified {
+		return FetchResult{NotModified: true}, nil
+	}
+
+	cal, err := ParseICalContentWithOptions(content, url, f.Options)
+	if err != nil {
+		return FetchResult{}, err
+	}
+
+	if f.Cache != nil {
+		entry.Content = content
+		if err := f.Cache.Set(remote, entry); err != nil {
+			return FetchResult{}, err
+		}
+	}
+
+	return FetchResult{Calendar: cal}, nil
+}
@@ -0,0 +1,37 @@
+package ics
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlHiddenRegex    = regexp.MustCompile(`(?is)<(script|style|head|title)\b.*?</(script|style|head|title)\s*>`)
+	htmlCommentRegex   = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlLineBreakRegex = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6]|blockquote|pre|table)\s*>`)
+	htmlTagRegex       = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesRegex    = regexp.MustCompile(`\n{3,}`)
+	spacesRegex        = regexp.MustCompile(`[ \t\r\f\v\x{a0}]+`)
+)
+
+// htmlToText returns the text of an HTML document, keeping line breaks for
+// the elements that would start a new line when rendered. The result only
+// contains text, so it is safe to show without any further sanitizing.
+func htmlToText(s string) string {
+	s = htmlHiddenRegex.ReplaceAllString(s, "")
+	s = htmlCommentRegex.ReplaceAllString(s, "")
+	s = strings.NewReplacer("\r\n", " ", "\n", " ").Replace(s)
+	s = htmlLineBreakRegex.ReplaceAllString(s, "\n")
+	s = htmlTagRegex.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = spacesRegex.ReplaceAllString(s, " ")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+
+	s = blankLinesRegex.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,73 @@
+package ics
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrImageTooLarge is returned by Image.Data when the image was bigger than
+// the maximum size allowed when the calendar was parsed.
+var ErrImageTooLarge = errors.New("ics: image exceeds the maximum size")
+
+// Image is a picture associated with a calendar or an event, either referenced
+// by its URI or included inline in the calendar.
+type Image struct {
+	URI     string
+	FmtType string
+	// Display lists the ways the image is intended to be shown: BADGE,
+	// GRAPHIC, FULLSIZE or THUMBNAIL.
+	Display []string
+	AltRep  string
+	// Size is the size of the decoded data of an inline image, or 0 for
+	// images referenced by URI.
+	Size int
+
+	encoded  string
+	inline   bool
+	tooLarge bool
+}
+
+// Inline tells whether the data of the image is included in the calendar
+// instead of being referenced by URI.
+func (img Image) Inline() bool {
+	return img.inline
+}
+
+// Data decodes and returns the data of an inline image, like
+// Attachment.Data. It returns nil for images referenced by URI.
+func (img Image) Data() ([]byte, error) {
+	if !img.inline {
+		return nil, nil
+	}
+
+	if img.tooLarge {
+		return nil, ErrImageTooLarge
+	}
+
+	return decodeBase64(img.encoded)
+}
+
+// parseImage parses an IMAGE property. Inline data bigger than maxSize bytes
+// is discarded, using the same limits as Options.MaxAttachmentSize.
+func parseImage(p property, maxSize int) Image {
+	img := Image{
+		FmtType: p.param("FMTTYPE"),
+		Display: p.Params["DISPLAY"],
+		AltRep:  p.param("ALTREP"),
+	}
+
+	if !strings.EqualFold(p.param("VALUE"), "BINARY") && !strings.EqualFold(p.param("ENCODING"), "BASE64") {
+		img.URI = p.Value
+		return img
+	}
+
+	img.inline = true
+	img.Size = decodedSize(p.Value)
+	if exceedsMaxSize(img.Size, maxSize) {
+		img.tooLarge = true
+		return img
+	}
+
+	img.encoded = p.Value
+	return img
+}
@@ -0,0 +1,86 @@
+package ics
+
+import "strings"
+
+// textProperties are the properties whose values are kept in Event.Texts
+// along with their language.
+var textProperties = []string{
+	"SUMMARY", "DESCRIPTION", "LOCATION", "COMMENT", "CONTACT",
+	"CATEGORIES", "RESOURCES", "X-ALT-DESC",
+}
+
+// LocalizedText is the value of a text property in a given language, which
+// is a BCP 47 tag such as "en-US", or empty if it was not specified.
+type LocalizedText struct {
+	Value    string
+	Language string
+}
+
+// Localized returns a copy of the event with its summary, description,
+// location and HTML description in the language that best matches lang,
+// a BCP 47 tag. A value in exactly that language is preferred, then one in
+// a less specific language ("de" for "de-CH"), then one in a variant of the
+// same language ("de-AT" for "de-CH"), then one without language and finally
+// the first one in the calendar.
+func (e *Event) Localized(lang string) *Event {
+	newEvent := e.Clone()
+	if t, ok := bestLanguageMatch(e.Texts["SUMMARY"], lang); ok {
+		newEvent.Summary = t.Value
+	}
+
+	if t, ok := bestLanguageMatch(e.Texts["DESCRIPTION"], lang); ok {
+		newEvent.Description = t.Value
+	}
+
+	if t, ok := bestLanguageMatch(e.Texts["LOCATION"], lang); ok {
+		newEvent.Location = t.Value
+	}
+
+	if t, ok := bestLanguageMatch(e.Texts["X-ALT-DESC"], lang); ok {
+		newEvent.HTMLDescription = unescapeText(t.Value)
+	}
+
+	return newEvent
+}
+
+func bestLanguageMatch(texts []LocalizedText, lang string) (LocalizedText, bool) {
+	if len(texts) == 0 {
+		return LocalizedText{}, false
+	}
+
+	best, bestRank := texts[0], languageMatchRank(texts[0].Language, lang)
+	for _, t := range texts[1:] {
+		if rank := languageMatchRank(t.Language, lang); rank < bestRank {
+			best, bestRank = t, rank
+		}
+	}
+
+	return best, true
+}
+
+// languageMatchRank tells how well the language of a value matches the
+// requested one. Lower ranks are better matches.
+func languageMatchRank(language, requested string) int {
+	language = strings.ToLower(strings.Replace(language, "_", "-", -1))
+	requested = strings.ToLower(strings.Replace(requested, "_", "-", -1))
+	if language == "" {
+		return 1000
+	}
+
+	if requested == "" {
+		return 2000
+	}
+
+	subtags := strings.Split(requested, "-")
+	for i := len(subtags); i > 0; i-- {
+		if language == strings.Join(subtags[:i], "-") {
+			return len(subtags) - i
+		}
+	}
+
+	if strings.Split(language, "-")[0] == subtags[0] {
+		return 100
+	}
+
+	return 2000
+}
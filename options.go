@@ -0,0 +1,64 @@
+package ics
+
+import "net/http"
+
+// DefaultMaxAttachmentSize is the maximum size of the inline attachments
+// whose data is kept when Options.MaxAttachmentSize is not set.
+const DefaultMaxAttachmentSize = 10 << 20
+
+// DefaultMaxBodySize is the maximum size of the remote calendars downloaded
+// when Options.MaxBodySize is not set.
+const DefaultMaxBodySize = 64 << 20
+
+// Options configures how calendars are parsed.
+type Options struct {
+	// MaxRepeats is the maximum number of occurrences added for each event
+	// with a repetition rule. Repetitions are not expanded if it is 0.
+	MaxRepeats int
+	// ConvertDatesToUTC makes all event dates be in UTC instead of in the
+	// time zone they were defined in.
+	ConvertDatesToUTC bool
+	// TraceErrFunc is called with the errors that don't stop the parsing,
+	// such as unknown time zones.
+	TraceErrFunc traceErrFunc
+	// MaxAttachmentSize is the maximum size in bytes of the decoded data of
+	// an inline attachment or image. The data of larger ones is discarded
+	// while parsing. DefaultMaxAttachmentSize is used if it is 0, and there
+	// is no limit if it is negative.
+	MaxAttachmentSize int
+	// Client is used to download remote calendars. http.DefaultClient is
+	// used if it is nil.
+	Client *http.Client
+	// Header holds headers added to the requests of remote calendars, such
+	// as an Authorization header with a bearer token.
+	Header http.Header
+	// PrepareRequest, if not nil, is called with every request made to get
+	// a remote calendar before it is sent, so credentials can be chosen by
+	// URL. Credentials in the calendar URL are sent with basic
+	// authentication, and are removed from the URL of the parsed calendar
+	// and from errors.
+	PrepareRequest func(req *http.Request) error
+	// MaxBodySize is the maximum size in bytes of a remote calendar, and of
+	// any compressed calendar once decompressed. Bigger calendars fail with
+	// ErrBodyTooLarge.
+	// DefaultMaxBodySize is used if it is 0, and there is no limit if it is
+	// negative.
+	MaxBodySize int64
+	// Retry configures how failed downloads of remote calendars are
+	// retried. They are not retried by default.
+	Retry RetryPolicy
+}
+
+func (o Options) maxBodySize() int64 {
+	if o.MaxBodySize == 0 {
+		return DefaultMaxBodySize
+	}
+	return o.MaxBodySize
+}
+
+func (o Options) client() *http.Client {
+	if o.Client == nil {
+		return http.DefaultClient
+	}
+	return o.Client
+}
@@ -1,9 +1,11 @@
 package ics
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	neturl "net/url"
 	"regexp"
 	"sort"
 	"strconv"
@@ -26,19 +28,15 @@ func (e *timezoneLocationCompatibilityError) Error() string {
 }
 
 var (
-	urlRegex                           = regexp.MustCompile(`https?:\/\/`)
+	urlSchemeRegex                     = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*):`)
+	userinfoRegex                      = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*://)[^/?#]*@`)
 	eventsRegex                        = regexp.MustCompile(`(BEGIN:VEVENT(.*\n)*?END:VEVENT\r?\n)`)
+	calendarsRegex                     = regexp.MustCompile(`(?s)BEGIN:VCALENDAR\r?\n.*?END:VCALENDAR`)
 	timezoneLocationCompatibilityRegex = regexp.MustCompile(`\s[0-9]`)
+	utcOffsetRegex                     = regexp.MustCompile(`(?i)^(?:(?:GMT|UTC)\s*)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	etcGMTOffsetRegex                  = regexp.MustCompile(`(?i)^Etc/GMT([+-])(\d{1,2})$`)
 
-	calNameRegex     = regexp.MustCompile(`X-WR-CALNAME:.*?\n`)
-	calDescRegex     = regexp.MustCompile(`X-WR-CALDESC:.*?\n`)
-	calVersionRegex  = regexp.MustCompile(`VERSION:.*?\n`)
-	calTimezoneRegex = regexp.MustCompile(`X-WR-TIMEZONE:.*?\n`)
-
-	eventSummaryRegex      = regexp.MustCompile(`SUMMARY:.*?\n`)
 	eventStatusRegex       = regexp.MustCompile(`STATUS:.*?\n`)
-	eventDescRegex         = regexp.MustCompile(`DESCRIPTION:.*?\n`)
-	eventUIDRegex          = regexp.MustCompile(`UID:.*?\n`)
 	eventClassRegex        = regexp.MustCompile(`CLASS:.*?\n`)
 	eventSequenceRegex     = regexp.MustCompile(`SEQUENCE:.*?\n`)
 	eventCreatedRegex      = regexp.MustCompile(`CREATED:.*?\n`)
@@ -50,25 +48,15 @@ var (
 	eventEndRegex          = regexp.MustCompile(`DTEND(;TZID=.*?){0,1}:.*?\n`)
 	eventEndWholeDayRegex  = regexp.MustCompile(`DTEND;VALUE=DATE:.*?\n`)
 	eventRRuleRegex        = regexp.MustCompile(`RRULE:.*?\n`)
-	eventLocationRegex     = regexp.MustCompile(`LOCATION:.*?\n`)
 	eventExDateRegex       = regexp.MustCompile(`EXDATE;TZID=(.*):(.*)\n`)
 
-	attendeesRegex = regexp.MustCompile(`ATTENDEE(:|;)(.*?\r?\n)(\s.*?\r?\n)*`)
-	organizerRegex = regexp.MustCompile(`ORGANIZER(:|;)(.*?\r?\n)(\s.*?\r?\n)*`)
-
-	attendeeEmailRegex  = regexp.MustCompile(`mailto:.*?\n`)
-	attendeeStatusRegex = regexp.MustCompile(`PARTSTAT=.*?;`)
-	attendeeRoleRegex   = regexp.MustCompile(`ROLE=.*?;`)
-	attendeeNameRegex   = regexp.MustCompile(`CN=.*?;`)
-	organizerNameRegex  = regexp.MustCompile(`CN=.*?:`)
-	attendeeTypeRegex   = regexp.MustCompile(`CUTYPE=.*?;`)
-
-	untilRegex    = regexp.MustCompile(`UNTIL=(\d)*T(\d)*Z(;){0,1}`)
+	untilRegex    = regexp.MustCompile(`UNTIL=(\d{8})(T\d{6}Z?)?`)
 	intervalRegex = regexp.MustCompile(`INTERVAL=(\d)*(;){0,1}`)
 	countRegex    = regexp.MustCompile(`COUNT=(\d)*(;){0,1}`)
-	freqRegex     = regexp.MustCompile(`FREQ=.*?;`)
-	byMonthRegex  = regexp.MustCompile(`BYMONTH=.*?;`)
-	byDayRegex    = regexp.MustCompile(`BYDAY=.*?(;|){0,1}\z`)
+	freqRegex     = regexp.MustCompile(`FREQ=[A-Z]+`)
+	byMonthRegex  = regexp.MustCompile(`BYMONTH=[0-9,]+`)
+	byDayRegex    = regexp.MustCompile(`BYDAY=([^;]*)`)
+	wkstRegex     = regexp.MustCompile(`WKST=[A-Z]{2}`)
 
 	nonStandardTimezones = map[string]string{
 		"Egypt Standard Time":             "Africa/Cairo",
@@ -227,12 +215,13 @@ var (
 )
 
 // ParseCalendar parses the calendar in the given url (can be a local path)
-// and returns the parsed calendar with its events. If maxRepeats is greater
+// and returns the parsed calendar with its events. The http, https, webcal,
+// webcals and file URL schemes are supported. If maxRepeats is greater
 // than 0 new events will be added if an event has a repetition rule up to
 // maxRepeats. If you pass a non-nil io.Writer the contents of the ics file
 // will also be written to that writer.
 func ParseCalendar(url string, maxRepeats int, w io.Writer) (Calendar, error) {
-	content, err := getICal(url)
+	content, err := getICal(context.Background(), url, Options{})
 	if err != nil {
 		return Calendar{}, err
 	}
@@ -246,50 +235,74 @@ func ParseCalendar(url string, maxRepeats int, w io.Writer) (Calendar, error) {
 	return ParseICalContent(content, url, maxRepeats, false, nil)
 }
 
-func getICal(url string) (string, error) {
-	var (
-		isRemote = urlRegex.FindString(url) != ""
-		content  string
-		err      error
-	)
+// ParseCalendarContext parses the calendar in the given url (can be a local
+// path) using the given options. Remote calendars are downloaded with
+// opts.Client, and the download is aborted if ctx is done before it
+// finishes.
+func ParseCalendarContext(ctx context.Context, url string, opts Options) (Calendar, error) {
+	content, err := getICal(ctx, url, opts)
+	if err != nil {
+		return Calendar{}, err
+	}
 
-	if isRemote {
-		content, err = downloadFromURL(url)
-		if err != nil {
-			return "", err
-		}
-	} else {
-		if !fileExists(url) {
-			return "", fmt.Errorf("file %s does not exists", url)
-		}
+	return ParseICalContentWithOptions(content, url, opts)
+}
 
-		contentBytes, err := ioutil.ReadFile(url)
-		if err != nil {
-			return "", err
-		}
-		content = string(contentBytes)
+func getICal(ctx context.Context, url string, opts Options) (string, error) {
+	remote, path, err := resolveCalendarURL(url)
+	if err != nil {
+		return "", err
 	}
 
-	return content, nil
+	if remote != "" {
+		content, _, _, err := download(ctx, remote, opts, CacheEntry{})
+		return content, err
+	}
+
+	if !fileExists(path) {
+		return "", fmt.Errorf("file %s does not exists", path)
+	}
+
+	contentBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	contentBytes, err = decompress(contentBytes, "", opts.maxBodySize())
+	if err != nil {
+		return "", fmt.Errorf("ics: reading %s: %w", path, err)
+	}
+
+	return decodeText(contentBytes, ""), nil
 }
 
 // ParseICalContent parses the calendar content as a string.
 // An optional error tracing function can be passed.
 func ParseICalContent(content, url string, maxRepeats int, convertDatesToUTC bool, fn traceErrFunc) (Calendar, error) {
+	return ParseICalContentWithOptions(content, url, Options{
+		MaxRepeats:        maxRepeats,
+		ConvertDatesToUTC: convertDatesToUTC,
+		TraceErrFunc:      fn,
+	})
+}
+
+// ParseICalContentWithOptions parses the calendar content as a string using
+// the given options.
+func ParseICalContentWithOptions(content, url string, opts Options) (Calendar, error) {
 	cal := NewCalendar()
-	eventsData, info := explodeICal(content)
-	cal.Name = parseICalName(info)
-	cal.Description = parseICalDesc(info)
-	cal.Version = parseICalVersion(info)
-	cal.URL = url
+	cal.maxAttachmentSize = opts.MaxAttachmentSize
+	eventsData, info := explodeICal(strings.TrimPrefix(content, "\ufeff"))
+	parseICalProperties(&cal, info)
+	cal.URL = redactURL(url)
 
+	fn := opts.TraceErrFunc
 	if fn == nil {
 		fn = func(err error) bool { return false }
 	}
 
 	cal.TraceErrFunc = fn
-	cal.convertDatesToUTC = convertDatesToUTC
-	err := parseEvents(&cal, eventsData, maxRepeats)
+	cal.convertDatesToUTC = opts.ConvertDatesToUTC
+	err := parseEvents(&cal, eventsData, opts.MaxRepeats)
 	if err != nil {
 		return cal, err
 	}
@@ -297,33 +310,67 @@ func ParseICalContent(content, url string, maxRepeats int, convertDatesToUTC boo
 	return cal, nil
 }
 
+// ParseAll parses every calendar in content, which can contain several
+// VCALENDAR objects one after the other, as found in concatenated exports or
+// in emails with several invitations. Each calendar only has the properties
+// and events of its own VCALENDAR object.
+func ParseAll(content, url string, opts Options) ([]Calendar, error) {
+	var calendars []Calendar
+	for _, data := range calendarsRegex.FindAllString(content, -1) {
+		cal, err := ParseICalContentWithOptions(data+"\n", url, opts)
+		if err != nil {
+			return calendars, err
+		}
+		calendars = append(calendars, cal)
+	}
+
+	return calendars, nil
+}
+
 func explodeICal(content string) ([]string, string) {
 	events := eventsRegex.FindAllString(content, -1)
 	info := eventsRegex.ReplaceAllString(content, "")
 	return events, info
 }
 
-func parseICalName(content string) string {
-	return trimField(calNameRegex.FindString(content), "X-WR-CALNAME:")
-}
+// parseICalProperties sets the calendar level properties found in content,
+// which must not contain the events of the calendar. RFC 7986 properties are
+// preferred over their non-standard X-WR equivalents.
+func parseICalProperties(cal *Calendar, content string) {
+	var vcalendar *component
+	for _, c := range parseComponents(content) {
+		if c.Name == "VCALENDAR" {
+			vcalendar = c
+			break
+		}
+	}
 
-func parseICalDesc(content string) string {
-	return trimField(calDescRegex.FindString(content), "X-WR-CALDESC:")
-}
+	if vcalendar == nil {
+		return
+	}
 
-func parseICalVersion(content string) float64 {
-	version, _ := strconv.ParseFloat(trimField(calVersionRegex.FindString(content), "VERSION:"), 64)
-	return version
-}
+	cal.Name = firstNonEmpty(vcalendar.value("NAME"), vcalendar.value("X-WR-CALNAME"))
+	cal.Description = firstNonEmpty(vcalendar.value("DESCRIPTION"), vcalendar.value("X-WR-CALDESC"))
+	cal.Version, _ = strconv.ParseFloat(vcalendar.value("VERSION"), 64)
+	cal.ProdID = vcalendar.value("PRODID")
+	cal.CalScale = vcalendar.value("CALSCALE")
+	cal.Method = vcalendar.value("METHOD")
+	cal.UID = vcalendar.value("UID")
+	cal.Color = vcalendar.value("COLOR")
+	cal.RefreshInterval, _ = parseDuration(firstNonEmpty(vcalendar.value("REFRESH-INTERVAL"), vcalendar.value("X-PUBLISHED-TTL")))
+	cal.LastModified, _ = time.Parse(icsFormat, vcalendar.value("LAST-MODIFIED"))
+
+	if source := vcalendar.value("SOURCE"); source != "" {
+		cal.Source, _ = neturl.Parse(source)
+	}
 
-func parseICalTimezone(content string) *time.Location {
-	timezone := trimField(calTimezoneRegex.FindString(content), "X-WR-TIMEZONE:")
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		return time.Local
+	for _, p := range vcalendar.properties("IMAGE") {
+		cal.Images = append(cal.Images, parseImage(p, cal.maxAttachmentSize))
 	}
 
-	return loc
+	if timezone := vcalendar.value("X-WR-TIMEZONE"); timezone != "" {
+		cal.Timezone, _ = parseLocation(timezone)
+	}
 }
 
 func eventIsDuplicated(events []Event, event *Event) (int, bool) {
@@ -376,21 +423,29 @@ func parseEvents(cal *Calendar, eventsData []string, maxRepeats int) error {
 			}
 		}
 
-		if end.IsZero() {
+		// As RFC 5545 says, an event starting on a date without an end lasts
+		// that whole day.
+		startsOnDate := findWithStart("DTSTART", eventWholeDayRegex.FindAllString(eventData, -1)) != ""
+		if end.IsZero() && startsOnDate {
+			end = start.AddDate(0, 0, 1)
+		} else if end.IsZero() {
 			end = time.Date(start.Year(), start.Month(), start.Day(), 23, 59, 59, 0, start.Location())
 		}
 
+		// Events spanning midnights are only whole day events in the zone
+		// they are presented in, so a TZID event from midnight to midnight is
+		// not one once converted to UTC.
+		wholeDay := isMidnight(start) && isMidnight(end)
 		if cal.convertDatesToUTC {
-			start = start.UTC()
-			end = end.UTC()
+			wholeDay = isMidnight(start.UTC()) && isMidnight(end.UTC())
 		}
-
-		wholeDay := start.Hour() == 0 && end.Hour() == 0 && start.Minute() == 0 && end.Minute() == 0 && start.Second() == 0 && end.Second() == 0
+		wholeDay = wholeDay || startsOnDate
 
 		event.Status = parseEventStatus(eventData)
-		event.Summary = parseEventSummary(eventData)
-		event.Description = parseEventDescription(eventData)
-		event.ID = parseEventID(eventData)
+		vevent := parseEventComponent(eventData)
+		event.Summary = parseEventSummary(vevent)
+		event.Description = parseEventDescription(vevent)
+		event.ID = parseEventID(vevent)
 		event.Class = parseEventClass(eventData)
 		event.Sequence = parseEventSequence(eventData)
 		event.Created = parseEventCreated(eventData)
@@ -406,109 +461,291 @@ func parseEvents(cal *Calendar, eventsData []string, maxRepeats int) error {
 			return err
 		}
 
-		event.Location = parseEventLocation(eventData)
+		event.Location = parseEventLocation(vevent)
 		event.Start = start
 		event.End = end
 		event.WholeDayEvent = wholeDay
-		event.Attendees = parseEventAttendees(eventData)
-		event.Organizer = parseEventOrganizer(eventData)
-		duration := end.Sub(start)
-		cal.Events = append(cal.Events, *event)
+		event.Attendees = parseEventAttendees(vevent)
+		event.Organizer = parseEventOrganizer(vevent)
+		event.Color = vevent.value("COLOR")
+		for _, p := range vevent.properties("CONFERENCE") {
+			event.Conferences = append(event.Conferences, parseConference(p))
+		}
+		for _, p := range vevent.properties("IMAGE") {
+			event.Images = append(event.Images, parseImage(p, cal.maxAttachmentSize))
+		}
+		event.Categories = parseListProperty(vevent, "CATEGORIES")
+		event.Resources = parseListProperty(vevent, "RESOURCES")
+		event.Priority, _ = strconv.Atoi(vevent.value("PRIORITY"))
+		event.Transparency = vevent.value("TRANSP")
+		event.URL = parseEventURL(vevent)
+		event.Geo = parseGeo(vevent.value("GEO"))
+		event.Comments = parseTextProperties(vevent, "COMMENT")
+		event.Contacts = parseTextProperties(vevent, "CONTACT")
+		event.RelatedTo = parseTextProperties(vevent, "RELATED-TO")
+		event.DTStamp, _ = time.Parse(icsFormat, vevent.value("DTSTAMP"))
+		event.HTMLDescription = parseEventHTMLDescription(vevent)
+		if p, ok := vevent.property("DESCRIPTION"); ok {
+			event.DescriptionAltRep = p.param("ALTREP")
+		}
+		for _, p := range vevent.properties("ATTACH") {
+			event.Attachments = append(event.Attachments, parseAttachment(p, cal.maxAttachmentSize))
+		}
+		event.Texts = parseLocalizedTexts(vevent)
+		event.Places = parsePlaces(vevent)
+		event.XProperties = parseXProperties(vevent)
 
+		// Recurrences are expanded from the dates in their original zone so
+		// that wall clock times are kept across DST transitions.
+		var occurrences, exceptions []Event
 		if maxRepeats > 0 && event.RRule != "" {
-			until := parseUntil(event.RRule)
-			interval := parseInterval(event.RRule)
-			count := parseCount(event.RRule, maxRepeats)
-			freq := trimField(freqRegex.FindString(event.RRule), `(FREQ=|;)`)
-			byMonth := trimField(byMonthRegex.FindString(event.RRule), `(BYMONTH=|;)`)
-			byDay := trimField(byDayRegex.FindString(event.RRule), `(BYDAY=|;)`)
-
-			var years, days, months int
-
-			switch freq {
-			case "DAILY":
-				days = interval
-			case "WEEKLY":
-				days = 7
-			case "MONTHLY":
-				months = interval
-			case "YEARLY":
-				years = interval
+			occurrences, exceptions = expandRecurrences(event, start, end, exclusions, maxRepeats)
+		}
+
+		if cal.convertDatesToUTC {
+			event = event.In(time.UTC)
+			for i := range occurrences {
+				occurrences[i] = *occurrences[i].In(time.UTC)
+			}
+			for i := range exceptions {
+				exceptions[i] = *exceptions[i].In(time.UTC)
 			}
+		}
 
-			current := 0
-			freqDate := event.Start
-
-			for {
-				weekDays := freqDate
-				commitEvent := func() {
-					current++
-					count--
-					newEvent := event.Clone()
-					newEvent.Start = weekDays
-					newEvent.End = weekDays.Add(duration)
-					newEvent.Sequence = current
-
-					for _, e := range exclusions {
-						if e.Equal(weekDays) {
-							excluded = append(excluded, *newEvent)
-							return
-						}
-					}
-
-					if until.IsZero() || (!until.IsZero() && (until.After(weekDays) || until.Equal(weekDays))) {
-						cal.Events = append(cal.Events, *newEvent)
-					}
-				}
+		cal.Events = append(cal.Events, *event)
+		cal.Events = append(cal.Events, occurrences...)
+		excluded = append(excluded, exceptions...)
+	}
 
-				if byMonth == "" || strings.Contains(byMonth, weekDays.Format("1")) {
-					if byDay != "" {
-						for i := 0; i < 7; i++ {
-							day := parseDayNameToIcsName(weekDays.Format("Mon"))
-							if strings.Contains(byDay, day) && weekDays != event.Start {
-								commitEvent()
-							}
-							weekDays = weekDays.AddDate(0, 0, 1)
-						}
-					} else {
-						if weekDays != event.Start {
-							commitEvent()
-						}
-					}
-				}
+	sort.Sort(byDate(cal.Events))
+	cal.Events = diff(ExcludeRecurrences(cal.Events), excluded)
 
-				freqDate = freqDate.AddDate(years, months, days)
-				if current > maxRepeats || count == 0 {
-					break
-				}
+	return nil
+}
 
-				if !until.IsZero() && (until.Before(freqDate) || until.Equal(freqDate)) {
-					break
-				}
+// expandRecurrences returns the occurrences of event generated by its
+// repetition rule, not including the one at start, along with the occurrences
+// removed by the exclusion dates. Occurrences are computed using the wall
+// clock of start in its own location, so an event at 10:00 stays at 10:00
+// after a DST transition.
+func expandRecurrences(event *Event, start, end time.Time, exclusions []time.Time, maxRepeats int) (occurrences, excluded []Event) {
+	until := parseUntil(event.RRule, start.Location())
+	interval := parseInterval(event.RRule)
+	count := parseCount(event.RRule)
+	freq := trimField(freqRegex.FindString(event.RRule), "FREQ=")
+	byMonth := trimField(byMonthRegex.FindString(event.RRule), "BYMONTH=")
+	byDay := parseByDay(event.RRule)
+	duration := end.Sub(start)
+
+	var years, months, days int
+	switch freq {
+	case "DAILY":
+		days = interval
+	case "WEEKLY":
+		days = 7 * interval
+	case "MONTHLY":
+		months = interval
+	case "YEARLY":
+		years = interval
+	default:
+		return nil, nil
+	}
+
+	// Dates are iterated in UTC so that adding days is never affected by
+	// the DST transitions of the event location.
+	first := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+	if freq == "WEEKLY" && len(byDay) > 0 {
+		wkst := parseWeekStart(event.RRule)
+		first = first.AddDate(0, 0, -((int(first.Weekday()) - int(wkst) + 7) % 7))
+	}
+
+	// The event at start is the first instance counted by COUNT, while
+	// maxRepeats limits the occurrences added after it. A negative
+	// remaining means there is no COUNT.
+	current := 0
+	remaining := count - 1
+
+	// The last day of the recurrence is taken in the event location, as
+	// the days being iterated are.
+	var untilDay time.Time
+	if !until.IsZero() {
+		u := until.In(start.Location())
+		untilDay = time.Date(u.Year(), u.Month(), u.Day(), 0, 0, 0, 0, time.UTC)
+	}
+
+	for period := 0; current < maxRepeats && remaining != 0; period++ {
+		var periodStart time.Time
+		switch {
+		case freq == "MONTHLY" && len(byDay) > 0:
+			periodStart = time.Date(first.Year(), first.Month()+time.Month(months*period), 1, 0, 0, 0, 0, time.UTC)
+		case freq == "YEARLY" && (len(byDay) > 0 || byMonth != ""):
+			periodStart = time.Date(first.Year()+years*period, time.January, 1, 0, 0, 0, 0, time.UTC)
+		default:
+			periodStart = time.Date(first.Year()+years*period, first.Month()+time.Month(months*period), first.Day()+days*period, 0, 0, 0, 0, time.UTC)
+		}
+
+		if !untilDay.IsZero() && periodStart.After(untilDay) {
+			break
+		}
+
+		// Rules whose filters never match would otherwise loop forever.
+		if periodStart.Year()-first.Year() > maxRecurrenceYears {
+			break
+		}
+
+		candidates := recurrenceCandidates(freq, periodStart, first.Day(), byDay, byMonth)
+		for _, day := range candidates {
+			if current >= maxRepeats || remaining == 0 {
+				break
+			}
+
+			if byMonth != "" && !containsValue(byMonth, day.Format("1")) {
+				continue
+			}
+
+			occurrence := localTime(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), start.Second(), start.Location())
+			if !occurrence.After(start) {
+				continue
+			}
+
+			if !until.IsZero() && occurrence.After(until) {
+				return occurrences, excluded
+			}
+
+			current++
+			if remaining > 0 {
+				remaining--
+			}
+			newEvent := event.Clone()
+			newEvent.Start = occurrence
+			newEvent.End = occurrence.Add(duration)
+
+			if isExcluded(occurrence, exclusions) {
+				excluded = append(excluded, *newEvent)
+				continue
 			}
+
+			occurrences = append(occurrences, *newEvent)
 		}
 	}
 
-	sort.Sort(byDate(cal.Events))
-	cal.Events = diff(ExcludeRecurrences(cal.Events), excluded)
+	return occurrences, excluded
+}
 
-	return nil
+// recurrenceCandidates returns the days of the period of a rule starting at
+// periodStart, before filtering them by BYMONTH. BYDAY only filters the days
+// of DAILY and WEEKLY rules, while it expands MONTHLY and YEARLY ones to the
+// matching days of the month or the year, honouring ordinals such as -1FR.
+// Dates that don't exist, such as February 30, are skipped as RFC 5545
+// requires instead of moving to the next month.
+func recurrenceCandidates(freq string, periodStart time.Time, monthDay int, byDay []weekdayRule, byMonth string) []time.Time {
+	var candidates []time.Time
+	switch {
+	case freq == "DAILY":
+		if len(byDay) == 0 || hasWeekday(byDay, periodStart.Weekday()) {
+			candidates = append(candidates, periodStart)
+		}
+	case freq == "WEEKLY" && len(byDay) > 0:
+		for i := 0; i < 7; i++ {
+			if day := periodStart.AddDate(0, 0, i); hasWeekday(byDay, day.Weekday()) {
+				candidates = append(candidates, day)
+			}
+		}
+	case freq == "WEEKLY":
+		candidates = append(candidates, periodStart)
+	case freq == "MONTHLY" && len(byDay) > 0:
+		candidates = matchingDays(periodStart, periodStart.AddDate(0, 1, 0), byDay)
+	case freq == "YEARLY" && len(byDay) > 0 && byMonth != "":
+		for month := periodStart; month.Year() == periodStart.Year(); month = month.AddDate(0, 1, 0) {
+			if containsValue(byMonth, month.Format("1")) {
+				candidates = append(candidates, matchingDays(month, month.AddDate(0, 1, 0), byDay)...)
+			}
+		}
+	case freq == "YEARLY" && len(byDay) > 0:
+		candidates = matchingDays(periodStart, periodStart.AddDate(1, 0, 0), byDay)
+	case freq == "YEARLY" && byMonth != "":
+		for month := time.January; month <= time.December; month++ {
+			day := time.Date(periodStart.Year(), month, monthDay, 0, 0, 0, 0, time.UTC)
+			if day.Day() == monthDay && containsValue(byMonth, strconv.Itoa(int(month))) {
+				candidates = append(candidates, day)
+			}
+		}
+	default:
+		if periodStart.Day() == monthDay {
+			candidates = append(candidates, periodStart)
+		}
+	}
+	return candidates
+}
+
+// matchingDays returns the days from start until end, excluded, that match
+// any of the rules, with ordinals counted within that span.
+func matchingDays(start, end time.Time, rules []weekdayRule) []time.Time {
+	var days []time.Time
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		for _, r := range rules {
+			if day.Weekday() != r.weekday {
+				continue
+			}
+
+			fromStart := int(day.Sub(start).Hours()/24) / 7
+			fromEnd := int(end.Sub(day).Hours()/24-1) / 7
+			if r.ordinal == 0 || r.ordinal == fromStart+1 || r.ordinal == -(fromEnd+1) {
+				days = append(days, day)
+				break
+			}
+		}
+	}
+	return days
+}
+
+func hasWeekday(rules []weekdayRule, weekday time.Weekday) bool {
+	for _, r := range rules {
+		if r.weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0
 }
 
-func parseEventSummary(eventData string) string {
-	return trimField(eventSummaryRegex.FindString(eventData), "SUMMARY:")
+func isExcluded(t time.Time, exclusions []time.Time) bool {
+	for _, e := range exclusions {
+		if e.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseEventSummary(vevent *component) string {
+	return vevent.value("SUMMARY")
 }
 
 func parseEventStatus(eventData string) string {
 	return trimField(eventStatusRegex.FindString(eventData), "STATUS:")
 }
 
-func parseEventDescription(eventData string) string {
-	return trimField(eventDescRegex.FindString(eventData), "DESCRIPTION:")
+func parseEventDescription(vevent *component) string {
+	return vevent.value("DESCRIPTION")
 }
 
-func parseEventID(eventData string) string {
-	return trimField(eventUIDRegex.FindString(eventData), "DSTAMP:")
+func parseEventID(vevent *component) string {
+	return vevent.value("UID")
+}
+
+// parseEventHTMLDescription returns the unescaped value of the first
+// X-ALT-DESC property with an HTML format type.
+func parseEventHTMLDescription(vevent *component) string {
+	for _, p := range vevent.properties("X-ALT-DESC") {
+		if strings.EqualFold(p.param("FMTTYPE"), "text/html") {
+			return unescapeText(p.Value)
+		}
+	}
+	return ""
 }
 
 func parseEventClass(eventData string) string {
@@ -570,10 +807,11 @@ func parseDatetime(data string) (time.Time, error) {
 	data = strings.TrimSpace(data)
 	var dataTz string
 	timeString := data
-	if strings.Contains(data, ":") {
-		dataParts := strings.Split(data, ":")
-		dataTz = dataParts[0]
-		timeString = dataParts[1]
+	// The value follows the last colon, since TZIDs such as "+05:30" can
+	// have colons too.
+	if i := strings.LastIndex(data, ":"); i >= 0 {
+		dataTz = data[:i]
+		timeString = data[i+1:]
 	}
 
 	if !strings.Contains(timeString, "Z") {
@@ -586,7 +824,7 @@ func parseDatetime(data string) (time.Time, error) {
 	}
 
 	if strings.Contains(dataTz, "TZID") {
-		loc, err := parseLocation(strings.Split(dataTz, "=")[1])
+		loc, err := parseLocation(strings.SplitN(dataTz, "=", 2)[1])
 
 		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc), err
 	}
@@ -595,16 +833,21 @@ func parseDatetime(data string) (time.Time, error) {
 }
 
 func parseLocation(location string) (*time.Location, error) {
-	timezone, err := time.LoadLocation(location)
+	location = strings.Trim(location, `"`)
+	timezone, err := loadLocation(location)
 	if err != nil {
+		if timezone, ok := parseOffsetLocation(location); ok {
+			return timezone, nil
+		}
+
 		loc, found := nonStandardTimezones[location]
 		if found {
-			timezone, err = time.LoadLocation(loc)
+			timezone, err = loadLocation(loc)
 		} else {
 			trimmedLoc := timezoneLocationCompatibilityRegex.ReplaceAllString(location, "")
 			loc, found = nonStandardTimezones[trimmedLoc]
 			if found {
-				timezone, err = time.LoadLocation(loc)
+				timezone, err = loadLocation(loc)
 				if err != nil {
 					return timezone, err
 				}
@@ -629,6 +872,33 @@ func parseLocation(location string) (*time.Location, error) {
 	return timezone, nil
 }
 
+// parseOffsetLocation returns a fixed zone for locations that are just an
+// offset from UTC, such as "GMT+0200", "UTC-3" or "+05:30". Etc/GMT zones
+// follow the POSIX convention, so "Etc/GMT-3" is three hours ahead of UTC.
+func parseOffsetLocation(location string) (*time.Location, bool) {
+	var sign, hours, minutes int
+	if m := utcOffsetRegex.FindStringSubmatch(location); m != nil {
+		sign = 1
+		hours, _ = strconv.Atoi(m[2])
+		minutes, _ = strconv.Atoi(m[3])
+	} else if m := etcGMTOffsetRegex.FindStringSubmatch(location); m != nil {
+		sign = -1
+		hours, _ = strconv.Atoi(m[2])
+	} else {
+		return nil, false
+	}
+
+	if hours > 14 || minutes > 59 {
+		return nil, false
+	}
+
+	if strings.Contains(location, "-") {
+		sign = -sign
+	}
+
+	return time.FixedZone(location, sign*(hours*3600+minutes*60)), true
+}
+
 func parseDate(data string) (time.Time, error) {
 	return parseDatetime(data + "T000000")
 }
@@ -667,26 +937,122 @@ func parseExcludedDates(eventData string, convertDatesToUTC bool) ([]time.Time,
 			t = t.UTC()
 		}
 
-		dates = append(dates)
+		dates = append(dates, t)
 	}
 
 	return dates, nil
 }
 
-func parseEventLocation(eventData string) string {
-	return trimField(eventLocationRegex.FindString(eventData), "LOCATION:")
+func parseEventLocation(vevent *component) string {
+	return vevent.value("LOCATION")
 }
 
-func parseEventAttendees(eventData string) []Attendee {
-	attendeesList := []Attendee{}
-	attendees := attendeesRegex.FindAllString(eventData, -1)
+// parseListProperty returns the values of all the properties with the given
+// name, which can be repeated and contain several comma separated values.
+func parseListProperty(c *component, name string) []string {
+	var values []string
+	for _, p := range c.properties(name) {
+		for _, v := range splitList(p.Value) {
+			if v = strings.TrimSpace(unescapeText(v)); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// parseTextProperties returns the unescaped values of all the properties
+// with the given name.
+func parseTextProperties(c *component, name string) []string {
+	var values []string
+	for _, p := range c.properties(name) {
+		values = append(values, unescapeText(p.Value))
+	}
+	return values
+}
 
-	for _, a := range attendees {
-		if a == "" {
+func parseEventURL(vevent *component) *neturl.URL {
+	value := vevent.value("URL")
+	if value == "" {
+		return nil
+	}
+
+	u, err := neturl.Parse(strings.TrimSpace(value))
+	if err != nil {
+		return nil
+	}
+	return u
+}
+
+// parseGeo parses a GEO value, a latitude and a longitude separated by a
+// semicolon. Some producers use a comma instead, which is also accepted.
+func parseGeo(value string) *Geo {
+	parts := strings.Split(value, ";")
+	if len(parts) != 2 {
+		parts = strings.Split(value, ",")
+	}
+
+	if len(parts) != 2 {
+		return nil
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return nil
+	}
+
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return nil
+	}
+
+	return &Geo{Latitude: lat, Longitude: lon}
+}
+
+func parseLocalizedTexts(c *component) map[string][]LocalizedText {
+	texts := make(map[string][]LocalizedText)
+	for _, name := range textProperties {
+		for _, p := range c.properties(name) {
+			texts[name] = append(texts[name], LocalizedText{
+				Value:    p.Value,
+				Language: p.param("LANGUAGE"),
+			})
+		}
+	}
+	return texts
+}
+
+func parseXProperties(c *component) map[string][]string {
+	var props map[string][]string
+	for _, p := range c.Properties {
+		if !strings.HasPrefix(p.Name, "X-") {
 			continue
 		}
-		attendee := parseAttendee(strings.Replace(strings.Replace(a, "\r", "", 1), "\n ", "", 1))
-		if attendee.Email != "" || attendee.Name != "" {
+
+		if props == nil {
+			props = make(map[string][]string)
+		}
+		props[p.Name] = append(props[p.Name], p.Value)
+	}
+	return props
+}
+
+// parseEventComponent returns the VEVENT component in eventData, with its
+// properties and subcomponents.
+func parseEventComponent(eventData string) *component {
+	for _, c := range parseComponents(eventData) {
+		if c.Name == "VEVENT" {
+			return c
+		}
+	}
+	return &component{Name: "VEVENT"}
+}
+
+func parseEventAttendees(vevent *component) []Attendee {
+	attendeesList := []Attendee{}
+	for _, p := range vevent.properties("ATTENDEE") {
+		attendee := parseAttendee(p)
+		if attendee.Address != "" || attendee.Name != "" {
 			attendeesList = append(attendeesList, attendee)
 		}
 	}
@@ -694,61 +1060,112 @@ func parseEventAttendees(eventData string) []Attendee {
 	return attendeesList
 }
 
-func parseEventOrganizer(eventData string) Attendee {
-	organizer := organizerRegex.FindString(eventData)
-	if organizer == "" {
+func parseEventOrganizer(vevent *component) Attendee {
+	p, ok := vevent.property("ORGANIZER")
+	if !ok {
 		return Attendee{}
 	}
 
-	organizer = strings.Replace(strings.Replace(organizer, "\r", "", 1), "\n ", "", 1)
-	return Attendee{
-		Email: parseAttendeeMail(organizer),
-		Name:  parseOrganizerName(organizer),
+	return parseCalendarUser(p)
+}
+
+// parseAttendee parses an ATTENDEE property, using the defaults defined in
+// RFC 5545 for the parameters that are not present.
+func parseAttendee(p property) Attendee {
+	attendee := parseCalendarUser(p)
+	if attendee.Status == "" {
+		attendee.Status = StatusNeedsAction
+	}
+
+	if attendee.Role == "" {
+		attendee.Role = RoleRequired
 	}
+
+	if attendee.Type == "" {
+		attendee.Type = TypeIndividual
+	}
+
+	return attendee
 }
 
-func parseAttendee(data string) Attendee {
+// parseCalendarUser parses the calendar user parameters shared by the
+// ATTENDEE and ORGANIZER properties.
+func parseCalendarUser(p property) Attendee {
 	return Attendee{
-		Email:  parseAttendeeMail(data),
-		Name:   parseAttendeeName(data),
-		Role:   parseAttendeeRole(data),
-		Status: parseAttendeeStatus(data),
-		Type:   parseAttendeeType(data),
+		Name:           p.param("CN"),
+		Address:        strings.TrimSpace(p.Value),
+		Email:          firstNonEmpty(p.param("EMAIL"), parseAttendeeMail(p.Value)),
+		Status:         ParticipationStatus(strings.ToUpper(p.param("PARTSTAT"))),
+		Role:           Role(strings.ToUpper(p.param("ROLE"))),
+		Type:           CalendarUserType(strings.ToUpper(p.param("CUTYPE"))),
+		RSVP:           strings.EqualFold(p.param("RSVP"), "TRUE"),
+		DelegatedTo:    p.Params["DELEGATED-TO"],
+		DelegatedFrom:  p.Params["DELEGATED-FROM"],
+		Member:         p.Params["MEMBER"],
+		SentBy:         p.param("SENT-BY"),
+		Dir:            p.param("DIR"),
+		Language:       p.param("LANGUAGE"),
+		ScheduleStatus: p.Params["SCHEDULE-STATUS"],
 	}
 }
 
-func parseAttendeeMail(attendeeData string) string {
-	return trimField(attendeeEmailRegex.FindString(attendeeData), "mailto:")
-}
+// parseAttendeeMail returns the email of a calendar user address, which is
+// only known for mailto: URIs. Schemes are case insensitive and some feeds
+// omit the scheme altogether, so a bare email address is also accepted.
+func parseAttendeeMail(address string) string {
+	address = strings.TrimSpace(address)
+	scheme := ""
+	if i := strings.IndexByte(address, ':'); i >= 0 {
+		scheme, address = address[:i], address[i+1:]
+	}
 
-func parseAttendeeStatus(attendeeData string) string {
-	return trimField(attendeeStatusRegex.FindString(attendeeData), `(PARTSTAT=|;)`)
-}
+	if scheme != "" && !strings.EqualFold(scheme, "mailto") {
+		return ""
+	}
 
-func parseAttendeeRole(attendeeData string) string {
-	return trimField(attendeeRoleRegex.FindString(attendeeData), `(ROLE=|;)`)
-}
+	if i := strings.IndexByte(address, '?'); i >= 0 {
+		address = address[:i]
+	}
 
-func parseAttendeeName(attendeeData string) string {
-	return trimField(attendeeNameRegex.FindString(attendeeData), `(CN=|;)`)
-}
+	if email, err := neturl.PathUnescape(address); err == nil {
+		address = email
+	}
 
-func parseOrganizerName(orgData string) string {
-	return trimField(organizerNameRegex.FindString(orgData), `(CN=|:)`)
-}
+	if !strings.Contains(address, "@") {
+		return ""
+	}
 
-func parseAttendeeType(attendeeData string) string {
-	return trimField(attendeeTypeRegex.FindString(attendeeData), `(CUTYPE=|;)`)
+	return address
 }
 
-func parseUntil(rrule string) time.Time {
-	until := trimField(untilRegex.FindString(rrule), `(UNTIL=|;)`)
-	var t time.Time
-	if until == "" {
-	} else {
-		t, _ = time.Parse(icsFormat, until)
+// parseUntil returns the UNTIL of rrule, or the zero time if it has none.
+// Dates and floating date times are taken in loc, the location of DTSTART,
+// and a date includes the whole day.
+func parseUntil(rrule string, loc *time.Location) time.Time {
+	m := untilRegex.FindStringSubmatch(rrule)
+	if m == nil {
+		return time.Time{}
 	}
-	return t
+
+	if strings.HasSuffix(m[2], "Z") {
+		t, _ := time.Parse(icsFormat, m[1]+m[2])
+		return t
+	}
+
+	layout, value := icsFormatWholeDay, m[1]
+	if m[2] != "" {
+		layout, value = "20060102T150405", m[1]+m[2]
+	}
+
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return time.Time{}
+	}
+
+	if m[2] == "" {
+		return localTime(t.Year(), t.Month(), t.Day(), 23, 59, 59, loc)
+	}
+	return localTime(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), loc)
 }
 
 func parseInterval(rrule string) int {
@@ -761,12 +1178,88 @@ func parseInterval(rrule string) int {
 	return i
 }
 
-func parseCount(rrule string, maxRepeats int) int {
-	c := trimField(countRegex.FindString(rrule), `(COUNT=|;)`)
-	count, _ := strconv.Atoi(c)
-	if count == 0 {
-		count = maxRepeats
+// weekdayRule is a BYDAY value, such as "MO" or "-1FR". The ordinal is 0
+// when the rule matches every such week day.
+type weekdayRule struct {
+	weekday time.Weekday
+	ordinal int
+}
+
+// parseByDay returns the rules in the BYDAY part of rrule, or nil if there
+// is none.
+func parseByDay(rrule string) []weekdayRule {
+	m := byDayRegex.FindStringSubmatch(rrule)
+	if m == nil || m[1] == "" {
+		return nil
 	}
 
+	var rules []weekdayRule
+	for _, value := range strings.Split(m[1], ",") {
+		value = strings.TrimSpace(value)
+		if len(value) < 2 {
+			continue
+		}
+
+		weekday, ok := parseWeekday(value[len(value)-2:])
+		if !ok {
+			continue
+		}
+
+		var ordinal int
+		if n := strings.TrimPrefix(value[:len(value)-2], "+"); n != "" {
+			if ordinal, ok = parseOrdinal(n); !ok {
+				continue
+			}
+		}
+
+		rules = append(rules, weekdayRule{weekday: weekday, ordinal: ordinal})
+	}
+	return rules
+}
+
+// parseOrdinal parses the ordinal of a BYDAY value, from -53 to 53.
+func parseOrdinal(value string) (int, bool) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n == 0 || n < -53 || n > 53 {
+		return 0, false
+	}
+	return n, true
+}
+
+func parseWeekStart(rrule string) time.Weekday {
+	if weekday, ok := parseWeekday(trimField(wkstRegex.FindString(rrule), "WKST=")); ok {
+		return weekday
+	}
+	return time.Monday
+}
+
+// parseWeekday parses a two letter week day such as "MO".
+func parseWeekday(day string) (time.Weekday, bool) {
+	switch day {
+	case "MO":
+		return time.Monday, true
+	case "TU":
+		return time.Tuesday, true
+	case "WE":
+		return time.Wednesday, true
+	case "TH":
+		return time.Thursday, true
+	case "FR":
+		return time.Friday, true
+	case "SA":
+		return time.Saturday, true
+	case "SU":
+		return time.Sunday, true
+	default:
+		return 0, false
+	}
+}
+
+// parseCount returns the COUNT of rrule, or 0 if it has none.
+func parseCount(rrule string) int {
+	count, _ := strconv.Atoi(trimField(countRegex.FindString(rrule), `(COUNT=|;)`))
+	if count < 0 {
+		return 0
+	}
 	return count
 }
@@ -1,6 +1,12 @@
 package ics
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 )
@@ -71,6 +77,11 @@ func TestCalendarEvents(t *testing.T) {
 	summary := "General Operative Meeting"
 	rrule := ""
 	attendeesCount := 3
+	id := "btb9tnpcnd4ng9rn31rdo0irn8@google.com"
+
+	if event.ID != id {
+		t.Errorf("Expected id %s, found %s\n", id, event.ID)
+	}
 
 	if !event.Start.Equal(start) {
 		t.Errorf("Expected start %s, found %s\n", start, event.Start)
@@ -205,3 +216,1017 @@ func TestParseEventDateWholeDay(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestWholeDayEventsInUTC(t *testing.T) {
+	content := "BEGIN:VCALENDAR\nVERSION:2.0\n" +
+		"BEGIN:VEVENT\nUID:zone@example.com\n" +
+		"DTSTART;TZID=Europe/Madrid:20240610T000000\nDTEND;TZID=Europe/Madrid:20240611T000000\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:date@example.com\n" +
+		"DTSTART;VALUE=DATE:20240612\nDTEND;VALUE=DATE:20240613\nEND:VEVENT\n" +
+		"BEGIN:VEVENT\nUID:noend@example.com\n" +
+		"DTSTART;VALUE=DATE:20240101\nEND:VEVENT\n" +
+		"END:VCALENDAR\n"
+
+	calendar, err := ParseICalContent(content, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calendar.Events) != 3 || !calendar.Events[0].WholeDayEvent || !calendar.Events[1].WholeDayEvent || !calendar.Events[2].WholeDayEvent {
+		t.Fatalf("expected 3 whole day events, got %+v", calendar.Events)
+	}
+
+	calendar, err = ParseICalContent(content, "", 0, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	noEnd := calendar.Events[0]
+	if expected := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC); !noEnd.WholeDayEvent || !noEnd.Start.Equal(expected) || !noEnd.End.Equal(expected.AddDate(0, 0, 1)) {
+		t.Errorf("expected event without end to last the whole day from %s, got %s to %s", expected, noEnd.Start, noEnd.End)
+	}
+
+	zoned := calendar.Events[1]
+	if expected := time.Date(2024, time.June, 9, 22, 0, 0, 0, time.UTC); zoned.WholeDayEvent || !zoned.Start.Equal(expected) {
+		t.Errorf("expected event in UTC from %s not to be whole day, got %s whole day %v", expected, zoned.Start, zoned.WholeDayEvent)
+	}
+
+	date := calendar.Events[2]
+	if expected := time.Date(2024, time.June, 12, 0, 0, 0, 0, time.UTC); !date.WholeDayEvent || !date.Start.Equal(expected) {
+		t.Errorf("expected whole day event at %s, got %s whole day %v", expected, date.Start, date.WholeDayEvent)
+	}
+}
+
+func dstCalendar(tzid, dtstart, dtend, rrule string) string {
+	return "BEGIN:VCALENDAR\nVERSION:2.0\n" +
+		"BEGIN:VEVENT\n" +
+		"DTSTART;TZID=" + tzid + ":" + dtstart + "\n" +
+		"DTEND;TZID=" + tzid + ":" + dtend + "\n" +
+		"RRULE:" + rrule + "\n" +
+		"UID:dst@example.com\n" +
+		"SUMMARY:DST\n" +
+		"END:VEVENT\n" +
+		"END:VCALENDAR\n"
+}
+
+func TestRecurrencesAcrossDST(t *testing.T) {
+	cases := []struct {
+		tzid     string
+		dtstart  string
+		dtend    string
+		rrule    string
+		expected []string
+	}{
+		{
+			"Europe/Madrid", "20240325T100000", "20240325T110000", "FREQ=WEEKLY;COUNT=3",
+			[]string{"2024-03-25T10:00:00+01:00", "2024-04-01T10:00:00+02:00", "2024-04-08T10:00:00+02:00"},
+		},
+		{
+			"Europe/Madrid", "20241021T100000", "20241021T110000", "FREQ=WEEKLY;COUNT=2",
+			[]string{"2024-10-21T10:00:00+02:00", "2024-10-28T10:00:00+01:00"},
+		},
+		{
+			"Europe/Madrid", "20240330T023000", "20240330T033000", "FREQ=DAILY;COUNT=3",
+			[]string{"2024-03-30T02:30:00+01:00", "2024-03-31T03:30:00+02:00", "2024-04-01T02:30:00+02:00"},
+		},
+		{
+			"America/New_York", "20241028T090000", "20241028T100000", "FREQ=WEEKLY;COUNT=2",
+			[]string{"2024-10-28T09:00:00-04:00", "2024-11-04T09:00:00-05:00"},
+		},
+		{
+			"America/New_York", "20240309T023000", "20240309T033000", "FREQ=DAILY;COUNT=3",
+			[]string{"2024-03-09T02:30:00-05:00", "2024-03-10T03:30:00-04:00", "2024-03-11T02:30:00-04:00"},
+		},
+		{
+			"America/New_York", "20241102T013000", "20241102T020000", "FREQ=DAILY;COUNT=2",
+			[]string{"2024-11-02T01:30:00-04:00", "2024-11-03T01:30:00-04:00"},
+		},
+		{
+			"Europe/Madrid", "20240610T100000", "20240610T110000", "FREQ=WEEKLY;BYDAY=MO;WKST=SU;COUNT=3",
+			[]string{"2024-06-10T10:00:00+02:00", "2024-06-17T10:00:00+02:00", "2024-06-24T10:00:00+02:00"},
+		},
+		{
+			"Europe/Madrid", "20240615T100000", "20240615T110000", "FREQ=WEEKLY;BYDAY=SA,SU;COUNT=3",
+			[]string{"2024-06-15T10:00:00+02:00", "2024-06-16T10:00:00+02:00", "2024-06-22T10:00:00+02:00"},
+		},
+		{
+			"Asia/Tokyo", "20240610T080000", "20240610T090000", "FREQ=DAILY;UNTIL=20240611T230000Z",
+			[]string{"2024-06-10T08:00:00+09:00", "2024-06-11T08:00:00+09:00", "2024-06-12T08:00:00+09:00"},
+		},
+		{
+			"Europe/Madrid", "20240610T100000", "20240610T110000", "FREQ=DAILY;UNTIL=20240612T100000",
+			[]string{"2024-06-10T10:00:00+02:00", "2024-06-11T10:00:00+02:00", "2024-06-12T10:00:00+02:00"},
+		},
+		{
+			"Europe/Madrid", "20240610T100000", "20240610T110000", "FREQ=DAILY;UNTIL=20240612",
+			[]string{"2024-06-10T10:00:00+02:00", "2024-06-11T10:00:00+02:00", "2024-06-12T10:00:00+02:00"},
+		},
+		{
+			"Europe/Madrid", "20240614T100000", "20240614T110000", "FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR;COUNT=8",
+			[]string{
+				"2024-06-14T10:00:00+02:00", "2024-06-17T10:00:00+02:00", "2024-06-18T10:00:00+02:00", "2024-06-19T10:00:00+02:00",
+				"2024-06-20T10:00:00+02:00", "2024-06-21T10:00:00+02:00", "2024-06-24T10:00:00+02:00", "2024-06-25T10:00:00+02:00",
+			},
+		},
+		{
+			"Europe/Madrid", "20240603T100000", "20240603T110000", "FREQ=MONTHLY;BYDAY=MO;COUNT=5",
+			[]string{
+				"2024-06-03T10:00:00+02:00", "2024-06-10T10:00:00+02:00", "2024-06-17T10:00:00+02:00",
+				"2024-06-24T10:00:00+02:00", "2024-07-01T10:00:00+02:00",
+			},
+		},
+		{
+			"Europe/Madrid", "20240126T100000", "20240126T110000", "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			[]string{"2024-01-26T10:00:00+01:00", "2024-02-23T10:00:00+01:00", "2024-03-29T10:00:00+01:00"},
+		},
+		{
+			"Europe/Madrid", "20240611T100000", "20240611T110000", "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			[]string{"2024-06-11T10:00:00+02:00", "2024-07-09T10:00:00+02:00", "2024-08-13T10:00:00+02:00"},
+		},
+		{
+			"America/New_York", "20241128T120000", "20241128T150000", "FREQ=YEARLY;BYMONTH=11;BYDAY=4TH;COUNT=3",
+			[]string{"2024-11-28T12:00:00-05:00", "2025-11-27T12:00:00-05:00", "2026-11-26T12:00:00-05:00"},
+		},
+		{
+			"Europe/Madrid", "20240115T100000", "20240115T110000", "FREQ=YEARLY;BYMONTH=1,7;COUNT=3",
+			[]string{"2024-01-15T10:00:00+01:00", "2024-07-15T10:00:00+02:00", "2025-01-15T10:00:00+01:00"},
+		},
+		{
+			"Europe/Madrid", "20240101T100000", "20240101T110000", "FREQ=YEARLY;BYDAY=-1SU;COUNT=2",
+			[]string{"2024-01-01T10:00:00+01:00", "2024-12-29T10:00:00+01:00"},
+		},
+		{
+			"Europe/Madrid", "20240131T100000", "20240131T110000", "FREQ=MONTHLY;COUNT=3",
+			[]string{"2024-01-31T10:00:00+01:00", "2024-03-31T10:00:00+02:00", "2024-05-31T10:00:00+02:00"},
+		},
+	}
+
+	for _, c := range cases {
+		for _, utc := range []bool{false, true} {
+			content := dstCalendar(c.tzid, c.dtstart, c.dtend, c.rrule)
+			calendar, err := ParseICalContent(content, "", 10, utc, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(calendar.Events) != len(c.expected) {
+				t.Errorf("%s %s: expected %d events, got %d", c.tzid, c.rrule, len(c.expected), len(calendar.Events))
+				continue
+			}
+
+			for i, e := range calendar.Events {
+				expected, _ := time.Parse(time.RFC3339, c.expected[i])
+				if !e.Start.Equal(expected) {
+					t.Errorf("%s %s: expected occurrence %d at %s, got %s", c.tzid, c.rrule, i, expected, e.Start)
+				}
+
+				if utc && e.Start.Location() != time.UTC {
+					t.Errorf("expected occurrence %d to be in UTC, got %s", i, e.Start.Location())
+				}
+			}
+		}
+	}
+}
+
+func TestRecurrenceLimits(t *testing.T) {
+	cases := []struct {
+		rrule      string
+		maxRepeats int
+		expected   int
+	}{
+		{"FREQ=DAILY", 3, 4},
+		{"FREQ=DAILY;COUNT=50", 3, 4},
+		{"FREQ=DAILY;COUNT=2", 3, 2},
+		{"FREQ=DAILY;COUNT=1", 3, 1},
+		{"FREQ=DAILY;COUNT=2", 0, 1},
+	}
+
+	for _, c := range cases {
+		content := dstCalendar("Europe/Madrid", "20240610T100000", "20240610T110000", c.rrule)
+		calendar, err := ParseICalContent(content, "", c.maxRepeats, false, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(calendar.Events) != c.expected {
+			t.Errorf("%s with max repeats %d: expected %d events, got %d", c.rrule, c.maxRepeats, c.expected, len(calendar.Events))
+		}
+	}
+}
+
+func TestWholeDayRecurrenceUntil(t *testing.T) {
+	content := "BEGIN:VCALENDAR\nVERSION:2.0\nBEGIN:VEVENT\nUID:allday@example.com\n" +
+		"DTSTART;VALUE=DATE:20240610\nDTEND;VALUE=DATE:20240611\nRRULE:FREQ=DAILY;UNTIL=20240612\n" +
+		"END:VEVENT\nEND:VCALENDAR\n"
+
+	calendar, err := ParseICalContent(content, "", 10, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calendar.Events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(calendar.Events))
+	}
+
+	if last := calendar.Events[2]; !last.WholeDayEvent || last.Start.Day() != 12 {
+		t.Errorf("expected last whole day event on the 12th, got %s", last.Start)
+	}
+}
+
+func TestParseOffsetLocation(t *testing.T) {
+	cases := []struct {
+		location string
+		offset   int
+	}{
+		{"GMT+0200", 2 * 3600},
+		{"UTC-3", -3 * 3600},
+		{"UTC+05:30", 5*3600 + 30*60},
+		{"+05:30", 5*3600 + 30*60},
+		{"-0930", -(9*3600 + 30*60)},
+		{"GMT", 0},
+		{"Etc/GMT-3", 3 * 3600},
+		{"Etc/GMT+5", -5 * 3600},
+		{`"GMT-0100"`, -3600},
+	}
+
+	ref := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.UTC)
+	for _, c := range cases {
+		loc, err := parseLocation(c.location)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", c.location, err)
+			continue
+		}
+
+		if _, offset := ref.In(loc).Zone(); offset != c.offset {
+			t.Errorf("expected %s to have offset %d, got %d", c.location, c.offset, offset)
+		}
+	}
+
+	if loc, ok := parseOffsetLocation("Etc/GMT-3"); !ok {
+		t.Errorf("expected Etc/GMT-3 to be parsed as an offset")
+	} else if _, offset := ref.In(loc).Zone(); offset != 3*3600 {
+		t.Errorf("expected Etc/GMT-3 to have offset %d, got %d", 3*3600, offset)
+	}
+
+	for _, location := range []string{"GMT+25", "+05:75", "Mars/Olympus_Mons"} {
+		if _, ok := parseOffsetLocation(location); ok {
+			t.Errorf("expected %s not to be parsed as an offset", location)
+		}
+	}
+
+	start, err := parseEventDate("DTSTART", "DTSTART;TZID=GMT+0200:20240610T100000\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := time.Date(2024, time.June, 10, 8, 0, 0, 0, time.UTC); !start.Equal(expected) {
+		t.Errorf("expected start %s, got %s", expected, start)
+	}
+
+	for _, tzid := range []string{"+05:30", "UTC+05:30", `"UTC+05:30"`} {
+		content := dstCalendar(tzid, "20240610T100000", "20240610T110000", "FREQ=DAILY;COUNT=2")
+		calendar, err := ParseICalContent(content, "", 10, false, nil)
+		if err != nil {
+			t.Fatalf("%s: %s", tzid, err)
+		}
+
+		expected := time.Date(2024, time.June, 10, 4, 30, 0, 0, time.UTC)
+		if len(calendar.Events) != 2 || !calendar.Events[0].Start.Equal(expected) || !calendar.Events[1].Start.Equal(expected.AddDate(0, 0, 1)) {
+			t.Errorf("%s: expected 2 events from %s, got %+v", tzid, expected, calendar.Events)
+		}
+	}
+}
+
+var testCalendarProperties = `BEGIN:VCALENDAR
+VERSION:2.0
+PRODID:-//Example Corp//Calendar 1.0//EN
+CALSCALE:GREGORIAN
+METHOD:PUBLISH
+UID:5FC53010-1267-4F8E-BC28-1D7AE55A7C99
+NAME:Team Calendar
+X-WR-CALNAME:Old Team Calendar
+X-WR-CALDESC:Meetings of the team
+X-WR-TIMEZONE:Europe/Madrid
+COLOR:turquoise
+REFRESH-INTERVAL;VALUE=DURATION:PT12H
+X-PUBLISHED-TTL:PT1H
+SOURCE;VALUE=URI:https://example.com/team.ics
+IMAGE;VALUE=URI;DISPLAY=BADGE,THUMBNAIL;FMTTYPE=image/png:https://example.com/team.png
+IMAGE;VALUE=BINARY;ENCODING=BASE64;FMTTYPE=image/gif:R0lGODlh
+LAST-MODIFIED:20240101T100000Z
+BEGIN:VTIMEZONE
+TZID:Europe/Madrid
+LAST-MODIFIED:20200101T000000Z
+END:VTIMEZONE
+BEGIN:VEVENT
+UID:event@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestCalendarProperties(t *testing.T) {
+	calendar, err := ParseICalContent(testCalendarProperties, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calendar.Name != "Team Calendar" {
+		t.Errorf("expected name %q, got %q", "Team Calendar", calendar.Name)
+	}
+
+	if calendar.Description != "Meetings of the team" {
+		t.Errorf("expected description %q, got %q", "Meetings of the team", calendar.Description)
+	}
+
+	if calendar.ProdID != "-//Example Corp//Calendar 1.0//EN" {
+		t.Errorf("unexpected prodid %q", calendar.ProdID)
+	}
+
+	if calendar.CalScale != "GREGORIAN" || calendar.Method != "PUBLISH" {
+		t.Errorf("unexpected calscale %q or method %q", calendar.CalScale, calendar.Method)
+	}
+
+	if calendar.UID != "5FC53010-1267-4F8E-BC28-1D7AE55A7C99" {
+		t.Errorf("unexpected uid %q", calendar.UID)
+	}
+
+	if calendar.Color != "turquoise" {
+		t.Errorf("expected color %q, got %q", "turquoise", calendar.Color)
+	}
+
+	if calendar.RefreshInterval != 12*time.Hour {
+		t.Errorf("expected refresh interval %s, got %s", 12*time.Hour, calendar.RefreshInterval)
+	}
+
+	if calendar.Source == nil || calendar.Source.String() != "https://example.com/team.ics" {
+		t.Errorf("unexpected source %v", calendar.Source)
+	}
+
+	if calendar.Timezone == nil || calendar.Timezone.String() != "Europe/Madrid" {
+		t.Errorf("unexpected timezone %v", calendar.Timezone)
+	}
+
+	if expected := time.Date(2024, time.January, 1, 10, 0, 0, 0, time.UTC); !calendar.LastModified.Equal(expected) {
+		t.Errorf("expected last modified %s, got %s", expected, calendar.LastModified)
+	}
+
+	if len(calendar.Images) != 2 {
+		t.Fatalf("expected 2 images, got %d", len(calendar.Images))
+	}
+
+	img := calendar.Images[0]
+	if img.URI != "https://example.com/team.png" || img.FmtType != "image/png" || len(img.Display) != 2 || img.Display[1] != "THUMBNAIL" {
+		t.Errorf("unexpected image %+v", img)
+	}
+
+	img = calendar.Images[1]
+	if data, err := img.Data(); img.URI != "" || !img.Inline() || img.Size != 6 || string(data) != "GIF89a" || err != nil {
+		t.Errorf("unexpected inline image %+v with data %q and error %v", img, data, err)
+	}
+
+	calendar, err = ParseICalContentWithOptions(testCalendarProperties, "", Options{MaxAttachmentSize: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := calendar.Images[1].Data(); err != ErrImageTooLarge {
+		t.Errorf("expected image to be too large, got %v", err)
+	}
+
+	invalid := parseImage(property{Params: map[string][]string{"ENCODING": {"BASE64"}}, Value: "R0lG*Dlh"}, 0)
+	if data, err := invalid.Data(); err == nil {
+		t.Errorf("expected an error decoding invalid data, got %q", data)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	cases := []struct {
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"P1W", 7 * 24 * time.Hour, true},
+		{"PT12H", 12 * time.Hour, true},
+		{"P1DT2H30M", 26*time.Hour + 30*time.Minute, true},
+		{"-PT15M", -15 * time.Minute, true},
+		{"P", 0, false},
+		{"PT", 0, false},
+		{"12H", 0, false},
+	}
+
+	for _, c := range cases {
+		d, ok := parseDuration(c.value)
+		if ok != c.ok || d != c.expected {
+			t.Errorf("expected %q to be %s (%v), got %s (%v)", c.value, c.expected, c.ok, d, ok)
+		}
+	}
+}
+
+var testAttendeesEvent = `BEGIN:VEVENT
+UID:attendees@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+ORGANIZER;CN="Doe, Jane";ROLE=CHAIR;PARTSTAT=ACCEPTED;SENT-BY="mailto:assistant@example.com":mailto:jane@example.com
+ATTENDEE;RSVP=TRUE;DELEGATED-TO="mailto:bob@example.com","mailto:carol@example.com";CN="Smith: John":mailto:john@example.com
+ATTENDEE;CN=Room 1;CUTYPE=room;ROLE=NON-PARTICIPANT;DIR="ldap://example.com:6666/o=ABC%20Industries";MEMBER="mailto:rooms@example.com";LANGUAGE=es;SCHEDULE-STATUS=2.0,2.8;EMAIL=room1@example.com;PARTSTAT=tentative:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6
+BEGIN:VALARM
+ACTION:EMAIL
+ATTENDEE:mailto:alarm@example.com
+END:VALARM
+END:VEVENT
+`
+
+func TestParseEventAttendees(t *testing.T) {
+	vevent := parseEventComponent(testAttendeesEvent)
+	attendees := parseEventAttendees(vevent)
+	if len(attendees) != 2 {
+		t.Fatalf("expected %d attendees, got %d", 2, len(attendees))
+	}
+
+	john := attendees[0]
+	if john.Name != "Smith: John" || john.Email != "john@example.com" {
+		t.Errorf("unexpected name %q or email %q", john.Name, john.Email)
+	}
+
+	if !john.RSVP || john.Status != StatusNeedsAction || john.Role != RoleRequired || john.Type != TypeIndividual {
+		t.Errorf("unexpected attendee parameters %+v", john)
+	}
+
+	if len(john.DelegatedTo) != 2 || john.DelegatedTo[1] != "mailto:carol@example.com" {
+		t.Errorf("unexpected delegates %v", john.DelegatedTo)
+	}
+
+	room := attendees[1]
+	if room.Name != "Room 1" || room.Email != "room1@example.com" {
+		t.Errorf("unexpected name %q or email %q", room.Name, room.Email)
+	}
+
+	if room.Type != TypeRoom || room.Role != RoleNonParticipant || room.Status != StatusTentative {
+		t.Errorf("unexpected attendee parameters %+v", room)
+	}
+
+	if room.Dir != "ldap://example.com:6666/o=ABC%20Industries" || room.Language != "es" {
+		t.Errorf("unexpected dir %q or language %q", room.Dir, room.Language)
+	}
+
+	if len(room.Member) != 1 || len(room.ScheduleStatus) != 2 || room.ScheduleStatus[1] != "2.8" {
+		t.Errorf("unexpected member %v or schedule status %v", room.Member, room.ScheduleStatus)
+	}
+
+	organizer := parseEventOrganizer(vevent)
+	if organizer.Name != "Doe, Jane" || organizer.Email != "jane@example.com" {
+		t.Errorf("unexpected organizer name %q or email %q", organizer.Name, organizer.Email)
+	}
+
+	if organizer.Role != RoleChair || organizer.Status != StatusAccepted || organizer.SentBy != "mailto:assistant@example.com" {
+		t.Errorf("unexpected organizer parameters %+v", organizer)
+	}
+}
+
+func TestParseAttendeeAddresses(t *testing.T) {
+	data := "BEGIN:VEVENT\r\n" +
+		"UID:addresses@example.com\r\n" +
+		"ATTENDEE;CUTYPE=INDIVIDUAL;ROLE=REQ-PARTICIPANT;PARTSTAT=NEEDS-ACTION;CN=Traví\r\n" +
+		" s M. Vollmer;X-NUM-GUESTS=0:mailto:travis.vollmer.with.a.very.long.addr\r\n" +
+		"\tess@dayrep.example.co\r\n" +
+		" m\r\n" +
+		"ATTENDEE;CN=Sue:MAILTO:Sue%20Z@Example.com?subject=hi\r\n" +
+		"ATTENDEE;CN=Resource:urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6\r\n" +
+		"ATTENDEE;CN=Phone:tel:+1-919-555-1234\r\n" +
+		"ATTENDEE;CN=Sip:SIP:alice@example.com\r\n" +
+		"ATTENDEE;CN=Web:https://example.com/users/bob\r\n" +
+		"ATTENDEE;CN=Bare:bare@example.com\r\n" +
+		"ATTENDEE:urn:uuid:9a1c6c3e-5b2f-4e1a-9a53-0c1d2e3f4a5b\r\n" +
+		"ATTENDEE;ROLE=NON-PARTICIPANT:sip:room@example.com\r\n" +
+		"END:VEVENT\r\n"
+
+	expected := []struct {
+		name    string
+		address string
+		email   string
+	}{
+		{"Travís M. Vollmer", "mailto:travis.vollmer.with.a.very.long.address@dayrep.example.com", "travis.vollmer.with.a.very.long.address@dayrep.example.com"},
+		{"Sue", "MAILTO:Sue%20Z@Example.com?subject=hi", "Sue Z@Example.com"},
+		{"Resource", "urn:uuid:f81d4fae-7dec-11d0-a765-00a0c91e6bf6", ""},
+		{"Phone", "tel:+1-919-555-1234", ""},
+		{"Sip", "SIP:alice@example.com", ""},
+		{"Web", "https://example.com/users/bob", ""},
+		{"Bare", "bare@example.com", "bare@example.com"},
+		{"", "urn:uuid:9a1c6c3e-5b2f-4e1a-9a53-0c1d2e3f4a5b", ""},
+		{"", "sip:room@example.com", ""},
+	}
+
+	attendees := parseEventAttendees(parseEventComponent(data))
+	if len(attendees) != len(expected) {
+		t.Fatalf("expected %d attendees, got %d", len(expected), len(attendees))
+	}
+
+	for i, a := range attendees {
+		if a.Name != expected[i].name || a.Address != expected[i].address || a.Email != expected[i].email {
+			t.Errorf("expected attendee %d to be %+v, got %q %q %q", i, expected[i], a.Name, a.Address, a.Email)
+		}
+	}
+}
+
+var testRFC7986Event = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:conference@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+SUMMARY:Weekly sync
+COLOR:dodgerblue
+CONFERENCE;VALUE=URI;FEATURE=PHONE,MODERATOR;LABEL=Moderator dial-in:tel:+1-412-555-0123,,,654321
+CONFERENCE;VALUE=URI;FEATURE=VIDEO;LANGUAGE=en;LABEL="Web video chat; HD":https://video-chat.example.com/;group-id=1234
+IMAGE;VALUE=URI;DISPLAY=BADGE;FMTTYPE=image/png:https://example.com/images/party.png
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseEventRFC7986Properties(t *testing.T) {
+	calendar, err := ParseICalContent(testRFC7986Event, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calendar.Events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(calendar.Events))
+	}
+
+	event := calendar.Events[0]
+	if event.Color != "dodgerblue" {
+		t.Errorf("expected color %q, got %q", "dodgerblue", event.Color)
+	}
+
+	if len(event.Conferences) != 2 {
+		t.Fatalf("expected 2 conferences, got %d", len(event.Conferences))
+	}
+
+	phone := event.Conferences[0]
+	if phone.URI != "tel:+1-412-555-0123,,,654321" || phone.Label != "Moderator dial-in" {
+		t.Errorf("unexpected conference %+v", phone)
+	}
+
+	if len(phone.Features) != 2 || phone.Features[0] != "PHONE" || phone.Features[1] != "MODERATOR" {
+		t.Errorf("unexpected features %v", phone.Features)
+	}
+
+	video := event.Conferences[1]
+	if video.URI != "https://video-chat.example.com/;group-id=1234" || video.Label != "Web video chat; HD" || video.Language != "en" {
+		t.Errorf("unexpected conference %+v", video)
+	}
+
+	if len(event.Images) != 1 {
+		t.Fatalf("expected 1 image, got %d", len(event.Images))
+	}
+
+	if img := event.Images[0]; img.URI != "https://example.com/images/party.png" || img.FmtType != "image/png" || img.Display[0] != "BADGE" {
+		t.Errorf("unexpected image %+v", img)
+	}
+}
+
+func TestEventConferenceInfo(t *testing.T) {
+	cases := []struct {
+		file      string
+		provider  string
+		joinURL   string
+		dialIns   []string
+		meetingID string
+	}{
+		{
+			"testCalendars/googleMeet.ics", ProviderGoogleMeet, "https://meet.google.com/abc-defg-hij",
+			[]string{"+16176754444"}, "abc-defg-hij",
+		},
+		{
+			"testCalendars/teams.ics", ProviderTeams,
+			"https://teams.microsoft.com/l/meetup-join/19%3ameeting_NjY4YzQ5MjQtZGFmMy00NzY2LWE3NzQtYzZiNTAzOWI5MmM3%40thread.v2/0?context=%7b%22Tid%22%3a%2212345%22%7d",
+			[]string{"+13238494874"}, "123456789012",
+		},
+		{
+			"testCalendars/zoom.ics", ProviderZoom, "https://us02web.zoom.us/j/85012345678?pwd=bXlQYXNzd29yZA",
+			[]string{"+16465588656", "+13017158592"}, "85012345678",
+		},
+	}
+
+	for _, c := range cases {
+		calendar, err := ParseCalendar(c.file, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(calendar.Events) != 1 {
+			t.Fatalf("%s: expected 1 event, got %d", c.file, len(calendar.Events))
+		}
+
+		info, ok := calendar.Events[0].ConferenceInfo()
+		if !ok {
+			t.Errorf("%s: expected conference info", c.file)
+			continue
+		}
+
+		if info.Provider != c.provider || info.JoinURL != c.joinURL || info.MeetingID != c.meetingID {
+			t.Errorf("%s: unexpected conference info %+v", c.file, info)
+		}
+
+		if len(info.DialIns) != len(c.dialIns) {
+			t.Errorf("%s: expected dial-ins %v, got %v", c.file, c.dialIns, info.DialIns)
+			continue
+		}
+
+		for i := range c.dialIns {
+			if info.DialIns[i] != c.dialIns[i] {
+				t.Errorf("%s: expected dial-ins %v, got %v", c.file, c.dialIns, info.DialIns)
+			}
+		}
+	}
+
+	event := Event{
+		Conferences: []Conference{
+			{URI: "tel:+1-412-555-0123,,,654321", Features: []string{"PHONE"}},
+			{URI: "https://video-chat.example.com/;group-id=1234", Features: []string{"VIDEO"}},
+		},
+		XProperties: map[string][]string{"X-GOOGLE-CONFERENCE": {"https://meet.google.com/abc-defg-hij"}},
+	}
+
+	info, ok := event.ConferenceInfo()
+	if !ok || info.JoinURL != "https://video-chat.example.com/;group-id=1234" || info.Provider != "" {
+		t.Errorf("expected CONFERENCE to take priority, got %+v", info)
+	}
+
+	if len(info.DialIns) != 1 || info.DialIns[0] != "+14125550123" {
+		t.Errorf("unexpected dial-ins %v", info.DialIns)
+	}
+
+	if _, ok := (&Event{Description: "See https://example.com/agenda"}).ConferenceInfo(); ok {
+		t.Errorf("expected no conference info for unknown links in the description")
+	}
+}
+
+var testDescriptiveEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:descriptive@example.com
+DTSTAMP:20240105T083000Z
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+CATEGORIES:APPOINTMENT,EDUCATION
+CATEGORIES:Meetings\, Talks
+RESOURCES:EASEL,PROJECTOR,VCR
+PRIORITY:2
+TRANSP:TRANSPARENT
+URL:https://example.com/events/descriptive?lang=en
+GEO:37.386013;-122.082932
+COMMENT:Bring the slides
+COMMENT:Parking is available
+CONTACT:Jim Dolittle\, ABC Industries\, +1-919-555-1234
+RELATED-TO:jsmith.part7.19960817T083000.xyzMail@example.com
+RELATED-TO;RELTYPE=SIBLING:19960401-080045-4000F192713-0052@example.com
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseEventDescriptiveProperties(t *testing.T) {
+	calendar, err := ParseICalContent(testDescriptiveEvent, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := calendar.Events[0]
+	expectStrings := func(name string, expected, got []string) {
+		if len(expected) != len(got) {
+			t.Errorf("expected %s %q, got %q", name, expected, got)
+			return
+		}
+
+		for i := range expected {
+			if expected[i] != got[i] {
+				t.Errorf("expected %s %q, got %q", name, expected, got)
+				return
+			}
+		}
+	}
+
+	expectStrings("categories", []string{"APPOINTMENT", "EDUCATION", "Meetings, Talks"}, event.Categories)
+	expectStrings("resources", []string{"EASEL", "PROJECTOR", "VCR"}, event.Resources)
+	expectStrings("comments", []string{"Bring the slides", "Parking is available"}, event.Comments)
+	expectStrings("contacts", []string{"Jim Dolittle, ABC Industries, +1-919-555-1234"}, event.Contacts)
+	expectStrings("related to", []string{
+		"jsmith.part7.19960817T083000.xyzMail@example.com",
+		"19960401-080045-4000F192713-0052@example.com",
+	}, event.RelatedTo)
+
+	if event.Priority != 2 {
+		t.Errorf("expected priority %d, got %d", 2, event.Priority)
+	}
+
+	if event.Transparency != "TRANSPARENT" {
+		t.Errorf("expected transparency %q, got %q", "TRANSPARENT", event.Transparency)
+	}
+
+	if event.URL == nil || event.URL.Host != "example.com" || event.URL.Query().Get("lang") != "en" {
+		t.Errorf("unexpected url %v", event.URL)
+	}
+
+	if event.Geo == nil || event.Geo.Latitude != 37.386013 || event.Geo.Longitude != -122.082932 {
+		t.Errorf("unexpected geo %+v", event.Geo)
+	}
+
+	if expected := time.Date(2024, time.January, 5, 8, 30, 0, 0, time.UTC); !event.DTStamp.Equal(expected) {
+		t.Errorf("expected dtstamp %s, got %s", expected, event.DTStamp)
+	}
+
+	for _, geo := range []string{"", "37.386013", "91;0", "a;b"} {
+		if g := parseGeo(geo); g != nil {
+			t.Errorf("expected %q not to be parsed, got %+v", geo, g)
+		}
+	}
+}
+
+var testAttachmentsEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:attachments@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+ATTACH;FMTTYPE=application/postscript;SIZE=2048:ftp://example.com/pub/reports/r-960812.ps
+ATTACH;FMTTYPE=text/plain;ENCODING=BASE64;VALUE=BINARY;X-FILENAME=hello.txt:SGVsbG8sIHdvcmxkIQ==
+ATTACH;FMTTYPE=text/plain;ENCODING=BASE64;VALUE=BINARY;FILENAME=big.txt:VGhpcyBhdHRhY2htZW50IGlzIHRvbyBiaWc=
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseEventAttachments(t *testing.T) {
+	calendar, err := ParseICalContentWithOptions(testAttachmentsEvent, "", Options{MaxAttachmentSize: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attachments := calendar.Events[0].Attachments
+	if len(attachments) != 3 {
+		t.Fatalf("expected 3 attachments, got %d", len(attachments))
+	}
+
+	uri := attachments[0]
+	if uri.Inline() || uri.URI != "ftp://example.com/pub/reports/r-960812.ps" || uri.FmtType != "application/postscript" || uri.Size != 2048 {
+		t.Errorf("unexpected attachment %+v", uri)
+	}
+
+	if data, err := uri.Data(); data != nil || err != nil {
+		t.Errorf("expected no data for an attachment by URI, got %v, %v", data, err)
+	}
+
+	inline := attachments[1]
+	if !inline.Inline() || inline.Filename != "hello.txt" || inline.Size != 13 {
+		t.Errorf("unexpected attachment %+v", inline)
+	}
+
+	data, err := inline.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "Hello, world!" {
+		t.Errorf("expected data %q, got %q", "Hello, world!", data)
+	}
+
+	big := attachments[2]
+	if !big.Inline() || big.Filename != "big.txt" || big.Size != 26 {
+		t.Errorf("unexpected attachment %+v", big)
+	}
+
+	if _, err := big.Data(); err != ErrAttachmentTooLarge {
+		t.Errorf("expected error %v, got %v", ErrAttachmentTooLarge, err)
+	}
+
+	calendar, err = ParseICalContentWithOptions(testAttachmentsEvent, "", Options{MaxAttachmentSize: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err = calendar.Events[0].Attachments[2].Data()
+	if err != nil || string(data) != "This attachment is too big" {
+		t.Errorf("expected data without size limit, got %q, %v", data, err)
+	}
+}
+
+var testHTMLDescriptionEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:html@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+DESCRIPTION;ALTREP="cid:part1.0001@example.org":
+X-ALT-DESC;FMTTYPE=text/html:<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 3.2//EN">\n
+ <html><head><title>Agenda</title><style>p { color: red\; }</style></head><b
+ ody><p>Agenda for <b>Monday</b>:</p><ul><li>Budget &amp\; costs</li><li>Ro
+ admap</li></ul><!-- hidden --><script>alert(1)</script>Bye<br/>Jane</body></h
+ tml>
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseEventHTMLDescription(t *testing.T) {
+	calendar, err := ParseICalContent(testHTMLDescriptionEvent, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := calendar.Events[0]
+	if event.DescriptionAltRep != "cid:part1.0001@example.org" {
+		t.Errorf("unexpected altrep %q", event.DescriptionAltRep)
+	}
+
+	if !strings.HasPrefix(event.HTMLDescription, "<!DOCTYPE HTML PUBLIC \"-//W3C//DTD HTML 3.2//EN\">\n<html>") {
+		t.Errorf("unexpected html description %q", event.HTMLDescription)
+	}
+
+	expected := "Agenda for Monday:\nBudget & costs\nRoadmap\nBye\nJane"
+	if text := event.PlainDescription(); text != expected {
+		t.Errorf("expected plain description %q, got %q", expected, text)
+	}
+
+	event.Description = `Agenda\, notes`
+	if text := event.PlainDescription(); text != "Agenda, notes" {
+		t.Errorf("expected plain description %q, got %q", "Agenda, notes", text)
+	}
+}
+
+var testPlacesEvent = `BEGIN:VCALENDAR
+VERSION:2.0
+BEGIN:VEVENT
+UID:places@example.com
+DTSTART:20240110T100000Z
+DTEND:20240110T110000Z
+LOCATION:Apple Park\n1 Apple Park Way\, Cupertino
+X-APPLE-STRUCTURED-LOCATION;VALUE=URI;X-ADDRESS="1 Apple Park Way\nCupertin
+ o, CA 95014";X-APPLE-RADIUS=141.1750506089954;X-APPLE-REFERENCEFRAME=1;X-TI
+ TLE=Apple Park:geo:37.334900,-122.009020
+BEGIN:VLOCATION
+UID:123456-abcdef-98765432
+NAME:Conference room 2
+DESCRIPTION:Second floor\, next to the elevators
+LOCATION-TYPE:conference-room
+GEO:37.335;-122.009
+URL:https://example.com/rooms/2
+END:VLOCATION
+BEGIN:VRESOURCE
+UID:projector-1
+NAME:Projector
+RESOURCE-TYPE:PROJECTOR
+END:VRESOURCE
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestParseEventPlaces(t *testing.T) {
+	calendar, err := ParseICalContent(testPlacesEvent, "", 0, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := calendar.Events[0]
+	if len(event.Places) != 3 {
+		t.Fatalf("expected 3 places, got %d", len(event.Places))
+	}
+
+	apple := event.Places[0]
+	if apple.Name != "Apple Park" || apple.Address != "1 Apple Park Way\nCupertino, CA 95014" {
+		t.Errorf("unexpected name %q or address %q", apple.Name, apple.Address)
+	}
+
+	if apple.Geo == nil || apple.Geo.Latitude != 37.3349 || apple.Geo.Longitude != -122.00902 || apple.Radius != 141.1750506089954 {
+		t.Errorf("unexpected geo %+v or radius %f", apple.Geo, apple.Radius)
+	}
+
+	room := event.Places[1]
+	if room.Name != "Conference room 2" || room.Description != "Second floor, next to the elevators" || room.Type != "conference-room" {
+		t.Errorf("unexpected place %+v", room)
+	}
+
+	if room.Geo == nil || room.Geo.Latitude != 37.335 || room.URL != "https://example.com/rooms/2" {
+		t.Errorf("unexpected geo %+v or url %q", room.Geo, room.URL)
+	}
+
+	if projector := event.Places[2]; projector.Name != "Projector" || projector.Type != "PROJECTOR" || projector.Geo != nil {
+		t.Errorf("unexpected place %+v", projector)
+	}
+
+	geo, radius := parseGeoURI("geo:48.2010,16.3695,183;u=40")
+	if geo == nil || geo.Latitude != 48.201 || geo.Longitude != 16.3695 || radius != 40 {
+		t.Errorf("unexpected geo %+v or radius %f", geo, radius)
+	}
+}
+
+func TestParseAll(t *testing.T) {
+	first, err := ioutil.ReadFile("testCalendars/2eventsCal.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := ioutil.ReadFile("testCalendars/3eventsNoAttendee.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := string(first) + string(second) + testCalendarProperties
+	calendars, err := ParseAll(content, "concatenated.ics", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calendars) != 3 {
+		t.Fatalf("expected %d calendars, got %d", 3, len(calendars))
+	}
+
+	expected := []struct {
+		name   string
+		events int
+	}{
+		{"2 Events Cal", 2},
+		{"Exams and meet with friend", 3},
+		{"Team Calendar", 1},
+	}
+
+	for i, cal := range calendars {
+		if cal.Name != expected[i].name {
+			t.Errorf("expected calendar %d name %q, got %q", i, expected[i].name, cal.Name)
+		}
+
+		if len(cal.Events) != expected[i].events {
+			t.Errorf("expected calendar %d to have %d events, got %d", i, expected[i].events, len(cal.Events))
+		}
+
+		if cal.URL != "concatenated.ics" {
+			t.Errorf("expected calendar %d url %q, got %q", i, "concatenated.ics", cal.URL)
+		}
+	}
+
+	if calendars[0].ProdID == calendars[2].ProdID {
+		t.Errorf("expected each calendar to keep its own prodid")
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestParseCalendarContext(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testCalendars")))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	opts := Options{MaxRepeats: 10, Client: &http.Client{Transport: transport}}
+	calendar, err := ParseCalendarContext(context.Background(), server.URL+"/2eventsCal.ics", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if calendar.Name != "2 Events Cal" || len(calendar.Events) != 2 {
+		t.Errorf("unexpected calendar %q with %d events", calendar.Name, len(calendar.Events))
+	}
+
+	if transport.requests != 1 {
+		t.Errorf("expected the given client to be used, got %d requests", transport.requests)
+	}
+
+	calendar, err = ParseCalendarContext(context.Background(), "testCalendars/2eventsCal.ics", opts)
+	if err != nil || calendar.Name != "2 Events Cal" {
+		t.Errorf("expected local file to be parsed, got %q, %v", calendar.Name, err)
+	}
+}
+
+func TestParseCalendarContextCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ParseCalendarContext(ctx, server.URL+"/slow.ics", Options{})
+	if err == nil {
+		t.Fatal("expected an error when the deadline is exceeded")
+	}
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded error, got %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the download to be aborted, took %s", elapsed)
+	}
+}
@@ -0,0 +1,91 @@
+package ics
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Place is a structured location of an event, such as a room or a venue.
+type Place struct {
+	Name        string
+	Description string
+	Address     string
+	Geo         *Geo
+	// Radius is the precision of Geo in meters, or 0 if it is unknown.
+	Radius float64
+	URL    string
+	// Type is the LOCATION-TYPE of a VLOCATION or the RESOURCE-TYPE of a
+	// VRESOURCE, such as "conference-room" or "ROOM".
+	Type string
+}
+
+// parsePlaces returns the places of an event, found in the Apple structured
+// location and the RFC 9073 VLOCATION and VRESOURCE subcomponents.
+func parsePlaces(vevent *component) []Place {
+	var places []Place
+	for _, p := range vevent.properties("X-APPLE-STRUCTURED-LOCATION") {
+		places = append(places, parseAppleStructuredLocation(p))
+	}
+
+	for _, c := range vevent.components("VLOCATION") {
+		place := parsePlaceComponent(c)
+		place.Type = unescapeText(c.value("LOCATION-TYPE"))
+		places = append(places, place)
+	}
+
+	for _, c := range vevent.components("VRESOURCE") {
+		place := parsePlaceComponent(c)
+		place.Type = unescapeText(c.value("RESOURCE-TYPE"))
+		places = append(places, place)
+	}
+
+	return places
+}
+
+func parseAppleStructuredLocation(p property) Place {
+	place := Place{
+		Name:    unescapeText(p.param("X-TITLE")),
+		Address: unescapeText(p.param("X-ADDRESS")),
+	}
+
+	place.Geo, place.Radius = parseGeoURI(p.Value)
+	if radius, err := strconv.ParseFloat(p.param("X-APPLE-RADIUS"), 64); err == nil {
+		place.Radius = radius
+	}
+
+	return place
+}
+
+func parsePlaceComponent(c *component) Place {
+	return Place{
+		Name:        unescapeText(c.value("NAME")),
+		Description: unescapeText(c.value("DESCRIPTION")),
+		Address:     unescapeText(c.value("X-ADDRESS")),
+		Geo:         parseGeo(c.value("GEO")),
+		URL:         strings.TrimSpace(c.value("URL")),
+	}
+}
+
+// parseGeoURI parses an RFC 5870 geo URI such as "geo:37.33,-122.03;u=35",
+// returning the position and its uncertainty in meters.
+func parseGeoURI(uri string) (*Geo, float64) {
+	if !strings.HasPrefix(strings.ToLower(uri), "geo:") {
+		return nil, 0
+	}
+
+	params := strings.Split(uri[len("geo:"):], ";")
+	coords := strings.Split(params[0], ",")
+	if len(coords) < 2 {
+		return nil, 0
+	}
+
+	geo := parseGeo(coords[0] + ";" + coords[1])
+	var radius float64
+	for _, param := range params[1:] {
+		if strings.HasPrefix(strings.ToLower(param), "u=") {
+			radius, _ = strconv.ParseFloat(param[len("u="):], 64)
+		}
+	}
+
+	return geo, radius
+}
@@ -0,0 +1,182 @@
+package ics
+
+import "strings"
+
+// property is a single content line of an iCalendar object, such as
+// `ATTENDEE;ROLE=CHAIR;CN="Doe, John":mailto:john@example.com`.
+type property struct {
+	Name   string
+	Params map[string][]string
+	Value  string
+}
+
+// param returns the first value of the given parameter, or an empty string if
+// the property does not have it.
+func (p property) param(name string) string {
+	if values := p.Params[name]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// component is an iCalendar component, such as VCALENDAR or VEVENT, with its
+// properties and subcomponents.
+type component struct {
+	Name       string
+	Properties []property
+	Components []*component
+}
+
+// property returns the first property with the given name.
+func (c *component) property(name string) (property, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return property{}, false
+}
+
+// properties returns all properties with the given name.
+func (c *component) properties(name string) []property {
+	var result []property
+	for _, p := range c.Properties {
+		if p.Name == name {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// value returns the value of the first property with the given name.
+func (c *component) value(name string) string {
+	p, _ := c.property(name)
+	return p.Value
+}
+
+// components returns the direct subcomponents with the given name.
+func (c *component) components(name string) []*component {
+	var result []*component
+	for _, sub := range c.Components {
+		if sub.Name == name {
+			result = append(result, sub)
+		}
+	}
+	return result
+}
+
+// parseComponents returns the top level components in content. Lines that
+// are not inside any component are ignored, as are malformed lines.
+func parseComponents(content string) []*component {
+	var (
+		result []*component
+		stack  []*component
+	)
+
+	for _, line := range unfold(content) {
+		p, ok := parseProperty(line)
+		if !ok {
+			continue
+		}
+
+		switch p.Name {
+		case "BEGIN":
+			c := &component{Name: strings.ToUpper(p.Value)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, c)
+			} else {
+				result = append(result, c)
+			}
+			stack = append(stack, c)
+		case "END":
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		default:
+			if len(stack) > 0 {
+				c := stack[len(stack)-1]
+				c.Properties = append(c.Properties, p)
+			}
+		}
+	}
+
+	return result
+}
+
+// unfold splits content in lines, joining the ones that were folded by
+// starting them with a space or a tab.
+func unfold(content string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.Replace(content, "\r\n", "\n", -1), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if len(lines) > 0 && len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// parseProperty parses an unfolded content line. Parameter values can be
+// quoted to contain ";", ":" and "," and parameters can have several values
+// separated by commas. Names of properties and parameters are uppercased.
+func parseProperty(line string) (property, bool) {
+	nameEnd := strings.IndexAny(line, ";:")
+	if nameEnd <= 0 {
+		return property{}, false
+	}
+
+	p := property{
+		Name:   strings.ToUpper(line[:nameEnd]),
+		Params: make(map[string][]string),
+	}
+
+	i := nameEnd
+	for i < len(line) && line[i] == ';' {
+		i++
+		eq := strings.IndexByte(line[i:], '=')
+		if eq < 0 {
+			return property{}, false
+		}
+
+		name := strings.ToUpper(line[i : i+eq])
+		i += eq + 1
+
+		for {
+			var value string
+			if i < len(line) && line[i] == '"' {
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return property{}, false
+				}
+				value = line[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				end := strings.IndexAny(line[i:], ";:,")
+				if end < 0 {
+					return property{}, false
+				}
+				value = line[i : i+end]
+				i += end
+			}
+
+			p.Params[name] = append(p.Params[name], value)
+			if i >= len(line) || line[i] != ',' {
+				break
+			}
+			i++
+		}
+	}
+
+	if i >= len(line) || line[i] != ':' {
+		return property{}, false
+	}
+
+	p.Value = line[i+1:]
+	return p, true
+}
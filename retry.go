@@ -0,0 +1,183 @@
+package ics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = time.Second
+	defaultRetryMaxDelay  = time.Minute
+)
+
+// RetryPolicy configures how downloads of remote calendars are retried after
+// network errors, timeouts and responses with status 408, 429, 500, 502, 503
+// or 504. Only GET and HEAD requests are retried. The zero value makes a single
+// attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of requests made for a download,
+	// including the first one.
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, doubled for each of
+	// the following ones. A random jitter of up to half the delay is
+	// subtracted from it. It defaults to one second.
+	BaseDelay time.Duration
+	// MaxDelay caps the delay between attempts. When the server asks with
+	// Retry-After for a longer one no more attempts are made. It defaults to
+	// one minute.
+	MaxDelay time.Duration
+	// Sleep, if not nil, is called to wait between attempts instead of a
+	// timer. It must return early with the error of ctx when it is done.
+	Sleep func(ctx context.Context, d time.Duration) error
+	// Now, if not nil, is used instead of time.Now to compute the delays
+	// given as dates by Retry-After.
+	Now func() time.Time
+}
+
+// RetryError is returned when a download failed after several attempts.
+type RetryError struct {
+	Attempts int
+	// Err is the error of the last attempt.
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("ics: giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// attemptsError returns err wrapped in a RetryError if the download was
+// attempted more than once.
+func attemptsError(attempts int, err error) error {
+	if attempts == 1 {
+		return err
+	}
+	return &RetryError{Attempts: attempts, Err: err}
+}
+
+// delay returns how long to wait before the given retry, counting from 1,
+// and false if the download must not be retried.
+func (p RetryPolicy) delay(retry int, err error) (time.Duration, bool) {
+	maxDelay := p.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) && httpErr.RetryAfter > 0 {
+		return httpErr.RetryAfter, httpErr.RetryAfter <= maxDelay
+	}
+
+	d := p.BaseDelay
+	if d <= 0 {
+		d = defaultRetryBaseDelay
+	}
+
+	for i := 1; i < retry && d < maxDelay; i++ {
+		d *= 2
+	}
+
+	if d > maxDelay {
+		d = maxDelay
+	}
+
+	return d - time.Duration(rand.Int63n(int64(d/2)+1)), true
+}
+
+func (p RetryPolicy) sleep(ctx context.Context, d time.Duration) error {
+	if p.Sleep != nil {
+		return p.Sleep(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (p RetryPolicy) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
+
+// retryable tells whether a request failing with err can be sent again.
+func retryable(ctx context.Context, req *http.Request, err error) bool {
+	if ctx.Err() != nil || (req.Method != http.MethodGet && req.Method != http.MethodHead) {
+		return false
+	}
+
+	var httpErr *HTTPError
+	if !errors.As(err, &httpErr) {
+		return transientError(err)
+	}
+
+	switch httpErr.StatusCode {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusInternalServerError,
+		http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// transientError tells whether err is a network error or a timeout, such as
+// a refused connection or a connection closed before the whole response was
+// received. Errors such as invalid certificates or corrupt content are not
+// transient.
+func transientError(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+
+	// Every error of http.Client.Do is a *url.Error, which is a net.Error
+	// itself, so the error it wraps is the one that tells.
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number
+// of seconds or an HTTP date, as a delay from now.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0
+	}
+
+	return date.Sub(now)
+}
@@ -0,0 +1,190 @@
+package ics
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshInterval is how often a Subscription refreshes a
+	// calendar when neither the subscription nor the calendar set it.
+	DefaultRefreshInterval = time.Hour
+	// minRefreshInterval is the shortest interval taken from a calendar, so
+	// a publisher cannot make subscribers poll continuously.
+	minRefreshInterval = time.Minute
+)
+
+// Subscription keeps a remote calendar up to date by fetching it
+// periodically, and notifies its subscribers when it changes. It is safe for
+// concurrent use.
+type Subscription struct {
+	url      string
+	fetcher  *Fetcher
+	interval time.Duration
+
+	mut         sync.RWMutex
+	cal         Calendar
+	loaded      bool
+	err         error
+	subscribers []func(Calendar)
+
+	startOnce sync.Once
+	cancel    context.CancelFunc
+	done      chan struct{}
+}
+
+// NewSubscription returns a subscription to the calendar at url, fetched
+// with fetcher. A fetcher with an in memory cache is used if it is nil. The
+// calendar is refreshed every interval, or if it is 0 as often as the
+// calendar suggests with REFRESH-INTERVAL or X-PUBLISHED-TTL, falling back
+// to DefaultRefreshInterval.
+func NewSubscription(fetcher *Fetcher, url string, interval time.Duration) *Subscription {
+	if fetcher == nil {
+		fetcher = NewFetcher(Options{}, NewMemoryCache())
+	}
+
+	return &Subscription{
+		url:      url,
+		fetcher:  fetcher,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Subscribe registers fn to be called with the new calendar every time it
+// changes, including the first time it is fetched. The functions are called
+// one after the other from the goroutine refreshing the calendar, so they
+// should not block.
+func (s *Subscription) Subscribe(fn func(Calendar)) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.subscribers = append(s.subscribers, fn)
+}
+
+// Start fetches the calendar and keeps refreshing it in a new goroutine
+// until ctx is done or Stop is called. Calls after the first one do nothing.
+func (s *Subscription) Start(ctx context.Context) {
+	s.startOnce.Do(func() {
+		ctx, cancel := context.WithCancel(ctx)
+		s.mut.Lock()
+		s.cancel = cancel
+		s.mut.Unlock()
+		go s.run(ctx)
+	})
+}
+
+// Stop stops refreshing the calendar, waiting for an ongoing refresh to
+// finish. The last calendar fetched is still available afterwards.
+func (s *Subscription) Stop() {
+	started := true
+	s.startOnce.Do(func() {
+		started = false
+		close(s.done)
+	})
+
+	s.mut.RLock()
+	cancel := s.cancel
+	s.mut.RUnlock()
+	if started && cancel != nil {
+		cancel()
+	}
+
+	<-s.done
+}
+
+// Calendar returns the last calendar fetched, and false if none has been
+// fetched yet. Its events must not be modified.
+func (s *Subscription) Calendar() (Calendar, bool) {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.cal, s.loaded
+}
+
+// Err returns the error of the last refresh, or nil if it succeeded. The
+// previous calendar is kept when a refresh fails.
+func (s *Subscription) Err() error {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.err
+}
+
+func (s *Subscription) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		s.refresh(ctx)
+
+		timer := time.NewTimer(s.nextInterval())
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Subscription) refresh(ctx context.Context) {
+	// The fetcher may already have validators for the URL, from another
+	// user or from a previous process, so the first request is not
+	// conditional to be sure to get a calendar.
+	s.mut.RLock()
+	loaded := s.loaded
+	s.mut.RUnlock()
+
+	result, err := s.fetcher.fetch(ctx, s.url, loaded)
+	if ctx.Err() != nil {
+		return
+	}
+
+	s.mut.Lock()
+	s.err = err
+	if err != nil || result.NotModified || (s.loaded && sameCalendar(s.cal, result.Calendar)) {
+		s.mut.Unlock()
+		return
+	}
+
+	s.cal = result.Calendar
+	s.loaded = true
+	subscribers := append([]func(Calendar){}, s.subscribers...)
+	s.mut.Unlock()
+
+	for _, fn := range subscribers {
+		fn(result.Calendar)
+	}
+}
+
+func (s *Subscription) nextInterval() time.Duration {
+	if s.interval > 0 {
+		return s.interval
+	}
+
+	s.mut.RLock()
+	interval := s.cal.RefreshInterval
+	s.mut.RUnlock()
+
+	if interval <= 0 {
+		return DefaultRefreshInterval
+	}
+
+	if interval < minRefreshInterval {
+		return minRefreshInterval
+	}
+
+	return interval
+}
+
+// sameCalendar tells whether two calendars have the same properties and
+// events. Events are compared with Compare, so changes of DTSTAMP alone
+// don't count.
+func sameCalendar(c1, c2 Calendar) bool {
+	if !Compare(c1, c2).Empty() {
+		return false
+	}
+
+	c1.TraceErrFunc, c2.TraceErrFunc = nil, nil
+	c1.Events, c2.Events = nil, nil
+	return reflect.DeepEqual(c1, c2)
+}
@@ -0,0 +1,157 @@
+package ics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type changingFeed struct {
+	mut      sync.Mutex
+	name     string
+	requests int
+}
+
+func (f *changingFeed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.requests++
+	// Like many publishers, the feed sets DTSTAMP to the time it is
+	// exported, so it changes on every request.
+	stamp := time.Date(2024, time.January, 1, 0, 0, f.requests, 0, time.UTC).Format(icsFormat)
+	w.Write([]byte("BEGIN:VCALENDAR\r\nX-WR-CALNAME:" + f.name + "\r\nX-PUBLISHED-TTL:PT5M\r\n" +
+		"BEGIN:VEVENT\r\nUID:event@example.com\r\nDTSTAMP:" + stamp + "\r\n" +
+		"DTSTART:20240110T100000Z\r\nDTEND:20240110T110000Z\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"))
+}
+
+func (f *changingFeed) set(name string) {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	f.name = name
+}
+
+func (f *changingFeed) count() int {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+	return f.requests
+}
+
+func TestSubscription(t *testing.T) {
+	feed := &changingFeed{name: "First"}
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	sub := NewSubscription(nil, server.URL, 10*time.Millisecond)
+	changes := make(chan string, 10)
+	sub.Subscribe(func(cal Calendar) { changes <- cal.Name })
+
+	if _, ok := sub.Calendar(); ok {
+		t.Error("expected no calendar before starting")
+	}
+
+	sub.Start(context.Background())
+	expectChange(t, changes, "First")
+
+	cal, ok := sub.Calendar()
+	if !ok || cal.Name != "First" || cal.RefreshInterval != 5*time.Minute {
+		t.Errorf("unexpected calendar %+v", cal)
+	}
+
+	for before := feed.count(); feed.count() < before+3; {
+		time.Sleep(time.Millisecond)
+	}
+
+	select {
+	case name := <-changes:
+		t.Errorf("expected no change while only DTSTAMP changes, got %q", name)
+	default:
+	}
+
+	feed.set("Second")
+	expectChange(t, changes, "Second")
+
+	sub.Stop()
+	stopped := feed.count()
+	time.Sleep(30 * time.Millisecond)
+	if feed.count() != stopped {
+		t.Error("expected no requests after stopping")
+	}
+
+	if cal, _ := sub.Calendar(); cal.Name != "Second" || sub.Err() != nil {
+		t.Errorf("expected last calendar to be kept, got %q and %v", cal.Name, sub.Err())
+	}
+}
+
+func expectChange(t *testing.T, changes <-chan string, expected string) {
+	t.Helper()
+	select {
+	case name := <-changes:
+		if name != expected {
+			t.Errorf("expected change to %q, got %q", expected, name)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for change to %q", expected)
+	}
+}
+
+func TestSubscriptionInterval(t *testing.T) {
+	sub := NewSubscription(nil, "https://example.com/calendar.ics", 0)
+	if d := sub.nextInterval(); d != DefaultRefreshInterval {
+		t.Errorf("expected default interval, got %s", d)
+	}
+
+	sub.cal.RefreshInterval = 10 * time.Second
+	if d := sub.nextInterval(); d != minRefreshInterval {
+		t.Errorf("expected minimum interval, got %s", d)
+	}
+
+	sub.cal.RefreshInterval = 12 * time.Hour
+	if d := sub.nextInterval(); d != 12*time.Hour {
+		t.Errorf("expected interval of the calendar, got %s", d)
+	}
+
+	sub.Stop()
+}
+
+func TestSubscriptionWarmCache(t *testing.T) {
+	feed := newTestFeed(t, "testCalendars/2eventsCal.ics")
+	feed.etag = `"v1"`
+	server := httptest.NewServer(feed)
+	defer server.Close()
+
+	// Another user of the fetcher already has the calendar, so a
+	// conditional request would not return it.
+	fetcher := NewFetcher(Options{}, NewMemoryCache())
+	if _, err := fetcher.Fetch(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := NewSubscription(fetcher, server.URL, time.Hour)
+	changes := make(chan string, 1)
+	sub.Subscribe(func(cal Calendar) { changes <- cal.Name })
+	sub.Start(context.Background())
+	defer sub.Stop()
+
+	expectChange(t, changes, "2 Events Cal")
+	if cal, ok := sub.Calendar(); !ok || len(cal.Events) != 2 {
+		t.Errorf("expected the calendar to be loaded, got %+v", cal)
+	}
+}
+
+func TestSubscriptionStopTwice(t *testing.T) {
+	sub := NewSubscription(nil, "https://example.com/calendar.ics", 0)
+	sub.Stop()
+	sub.Stop()
+
+	server := httptest.NewServer(&changingFeed{name: "Stopped"})
+	defer server.Close()
+
+	sub = NewSubscription(nil, server.URL, 0)
+	sub.Start(context.Background())
+	sub.Stop()
+	sub.Stop()
+}
@@ -0,0 +1,40 @@
+package ics
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+const systemTZDataFile = "/usr/share/zoneinfo/tzdata.zi"
+
+var (
+	// loadLocation is used for every time zone lookup done by the parser.
+	// Building with the ics_tzdata tag replaces it with a lookup on the copy
+	// of the IANA database embedded in the package.
+	loadLocation = time.LoadLocation
+
+	tzdataVersion = systemTZDataVersion
+)
+
+// TZDataVersion returns the version of the IANA time zone database used to
+// resolve time zones, such as "2024a", or an empty string if it is unknown.
+func TZDataVersion() string {
+	return tzdataVersion()
+}
+
+func systemTZDataVersion() string {
+	f, err := os.Open(systemTZDataFile)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadString('\n')
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.TrimPrefix(line, "# version"))
+}
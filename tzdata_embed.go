@@ -0,0 +1,92 @@
+//go:build ics_tzdata
+
+package ics
+
+import (
+	"archive/zip"
+	"bytes"
+	_ "embed"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The embedded database is a copy of $GOROOT/lib/time/zoneinfo.zip. To update
+// it, copy that file from a Go release into the tzdata directory and write
+// the version it was built from to tzdata/VERSION.
+
+//go:embed tzdata/zoneinfo.zip
+var embeddedTZData []byte
+
+//go:embed tzdata/VERSION
+var embeddedTZDataVersion string
+
+var embeddedTZ struct {
+	sync.Mutex
+	zip       *zip.Reader
+	err       error
+	locations map[string]*time.Location
+}
+
+func init() {
+	loadLocation = loadEmbeddedLocation
+	tzdataVersion = func() string {
+		return strings.TrimSpace(embeddedTZDataVersion)
+	}
+}
+
+// loadEmbeddedLocation works like time.LoadLocation but only looks up zones
+// in the embedded database, so results don't depend on the host.
+func loadEmbeddedLocation(name string) (*time.Location, error) {
+	switch name {
+	case "", "UTC":
+		return time.UTC, nil
+	case "Local":
+		return time.Local, nil
+	}
+
+	embeddedTZ.Lock()
+	defer embeddedTZ.Unlock()
+
+	if loc, ok := embeddedTZ.locations[name]; ok {
+		return loc, nil
+	}
+
+	if embeddedTZ.zip == nil && embeddedTZ.err == nil {
+		embeddedTZ.zip, embeddedTZ.err = zip.NewReader(bytes.NewReader(embeddedTZData), int64(len(embeddedTZData)))
+		embeddedTZ.locations = make(map[string]*time.Location)
+	}
+
+	if embeddedTZ.err != nil {
+		return nil, embeddedTZ.err
+	}
+
+	for _, f := range embeddedTZ.zip.File {
+		if f.Name != name {
+			continue
+		}
+
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		loc, err := time.LoadLocationFromTZData(name, data)
+		if err != nil {
+			return nil, err
+		}
+
+		embeddedTZ.locations[name] = loc
+		return loc, nil
+	}
+
+	return nil, errors.New("unknown time zone " + name)
+}
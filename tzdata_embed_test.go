@@ -0,0 +1,28 @@
+//go:build ics_tzdata
+
+package ics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEmbeddedTZData(t *testing.T) {
+	if v := TZDataVersion(); v == "" {
+		t.Errorf("expected embedded tzdata version")
+	}
+
+	loc, err := loadLocation("Europe/Madrid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summer := time.Date(2024, time.July, 1, 12, 0, 0, 0, loc)
+	if _, offset := summer.Zone(); offset != 2*3600 {
+		t.Errorf("expected offset %d, got %d", 2*3600, offset)
+	}
+
+	if _, err := loadLocation("Mars/Olympus_Mons"); err == nil {
+		t.Errorf("expected an error for an unknown time zone")
+	}
+}
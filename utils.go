@@ -1,38 +1,93 @@
 package ics
 
 import (
-	"io/ioutil"
-	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	uts               = "1136239445"
 	icsFormat         = "20060102T150405Z"
 	icsFormatWholeDay = "20060102"
+
+	maxRecurrenceYears = 100
 )
 
-func downloadFromURL(url string) (string, error) {
-	response, err := http.Get(url)
-	if err != nil {
-		return "", err
+func trimField(field, cutset string) string {
+	re, _ := regexp.Compile(cutset)
+	cutsetRem := re.ReplaceAllString(field, "")
+	return strings.TrimRight(cutsetRem, "\r\n")
+}
+
+var durationRegex = regexp.MustCompile(`^([+-])?P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseDuration parses an RFC 5545 duration such as "P1W" or "-PT15M". Days
+// and weeks are taken as 24 hours and 7 days respectively.
+func parseDuration(value string) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	m := durationRegex.FindStringSubmatch(value)
+	if m == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, false
+	}
+
+	units := []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, unit := range units {
+		n, _ := strconv.Atoi(m[i+2])
+		d += time.Duration(n) * unit
 	}
-	defer response.Body.Close()
 
-	contents, err := ioutil.ReadAll(response.Body)
-	if err != nil {
-		return "", err
+	if m[1] == "-" {
+		d = -d
 	}
 
-	return string(contents), nil
+	return d, true
 }
 
-func trimField(field, cutset string) string {
-	re, _ := regexp.Compile(cutset)
-	cutsetRem := re.ReplaceAllString(field, "")
-	return strings.TrimRight(cutsetRem, "\r\n")
+// unescapeText reverts the escaping of TEXT values: `\n` and `\N` become a
+// new line, and backslashes, semicolons and commas lose their escaping
+// backslash.
+func unescapeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			if value[i] == 'n' || value[i] == 'N' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// splitList splits a list of TEXT values on the commas that are not escaped.
+// The values are returned still escaped.
+func splitList(value string) []string {
+	var (
+		values []string
+		start  int
+	)
+
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, value[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(values, value[start:])
 }
 
 func fileExists(fileName string) bool {
@@ -40,23 +95,55 @@ func fileExists(fileName string) bool {
 	return err == nil
 }
 
-func parseDayNameToIcsName(day string) string {
-	switch day {
-	case "Mon":
-		return "MO"
-	case "Tue":
-		return "TU"
-	case "Wed":
-		return "WE"
-	case "Thu":
-		return "TH"
-	case "Fri":
-		return "FR"
-	case "Sat":
-		return "ST"
-	case "Sun":
-		return "SU"
-	default:
-		return ""
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
 	}
+	return ""
+}
+
+func containsValue(list, value string) bool {
+	for _, v := range strings.Split(list, ",") {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// localTime returns the time with the given wall clock in loc. Following
+// RFC 5545 section 3.3.5, a wall clock that falls in the gap of a forward
+// DST shift is interpreted using the UTC offset before the gap, and one that
+// happens twice after a backward shift resolves to its first occurrence.
+func localTime(year int, month time.Month, day, hour, min, sec int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, hour, min, sec, 0, loc)
+	_, before := t.Add(-24 * time.Hour).Zone()
+	_, after := t.Add(24 * time.Hour).Zone()
+	if before == after {
+		return t
+	}
+
+	wall := time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	offsets := []int{before, after}
+	if after > before {
+		offsets = []int{after, before}
+	}
+
+	for _, offset := range offsets {
+		candidate := wall.Add(-time.Duration(offset) * time.Second).In(loc)
+		if sameWallClock(candidate, wall) {
+			return candidate
+		}
+	}
+
+	return wall.Add(-time.Duration(before) * time.Second).In(loc)
+}
+
+func sameWallClock(t1, t2 time.Time) bool {
+	y1, m1, d1 := t1.Date()
+	y2, m2, d2 := t2.Date()
+	return y1 == y2 && m1 == m2 && d1 == d2 &&
+		t1.Hour() == t2.Hour() && t1.Minute() == t2.Minute() && t1.Second() == t2.Second()
 }